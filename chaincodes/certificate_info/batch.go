@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// DefaultMaxBatchSize is maximum number of certificates per batch unless configured by SetMaxBatchSize
+	DefaultMaxBatchSize = 500
+
+	configObjectType = "config"
+	configBatchSize  = "max_batch_size"
+)
+
+// BatchItemResult describes issuance result of one certificate in batch
+type BatchItemResult struct {
+	CertificateKey string `json:"certificate_key"`
+	Issued         bool   `json:"issued"`
+	Error          string `json:"error"`
+}
+
+// BatchIssueResult describes issuance result of certificates batch
+type BatchIssueResult struct {
+	Issued int               `json:"issued"`
+	Failed int               `json:"failed"`
+	Items  []BatchItemResult `json:"items"`
+}
+
+// IssueCertificatesBatch add multiple certificates into ledger in one transaction.
+// Certificate holder data is passed in transient map as object of CertificatePii keyed by certificate key.
+// All certificates are validated first (including duplicates within batch and against ledger).
+// If atomic is true, nothing is written when any certificate is invalid and the per-item errors are returned as error,
+// otherwise valid certificates are written and invalid ones reported in the result.
+func (s *SmartContract) IssueCertificatesBatch(ctx contractapi.TransactionContextInterface,
+	certificates []CertificatePayload, atomic bool) (*BatchIssueResult, error) {
+
+	maxBatchSize, err := getMaxBatchSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("Batch must contain at least one certificate")
+	}
+
+	if len(certificates) > maxBatchSize {
+		return nil, fmt.Errorf("Batch size %d exceeds maximum batch size %d", len(certificates), maxBatchSize)
+	}
+
+	pii, err := getTransientBatchPii(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BatchIssueResult{
+		Items: make([]BatchItemResult, len(certificates)),
+	}
+	records := make([]*CertificateRecord, len(certificates))
+	tx := newIssuance()
+	seen := map[string]int{}
+
+	for i := range certificates {
+		payload := &certificates[i]
+		item := &result.Items[i]
+		item.CertificateKey = payload.CertificateKey
+
+		if first, ok := seen[payload.CertificateKey]; ok {
+			item.Error = fmt.Sprintf("Certificate %s duplicated in batch at index %d", payload.CertificateKey, first)
+			result.Failed++
+			continue
+		}
+		seen[payload.CertificateKey] = i
+
+		records[i], err = s.prepareCertificate(ctx, payload, pii[payload.CertificateKey], tx)
+		if err != nil {
+			item.Error = err.Error()
+			result.Failed++
+		}
+	}
+
+	if atomic && result.Failed > 0 {
+		return nil, fmt.Errorf("Batch rejected, %d of %d certificates invalid: %s", result.Failed, len(certificates), batchErrors(result))
+	}
+
+	entries := []CertificateEventEntry{}
+	for i, certificate := range records {
+		if certificate == nil {
+			continue
+		}
+
+		certKey := certificates[i].CertificateKey
+		err = putCertificate(ctx, certKey, certificate)
+		if err != nil {
+			return nil, err
+		}
+
+		err = putCertificatePii(ctx, certKey, certificate, pii[certKey])
+		if err != nil {
+			return nil, err
+		}
+
+		err = putCertificateIndexes(ctx, certKey, certificate, pii[certKey])
+		if err != nil {
+			return nil, err
+		}
+
+		result.Items[i].Issued = true
+		result.Issued++
+		entries = append(entries, newEventEntry(certKey, certificate))
+	}
+
+	if len(entries) > 0 {
+		err = setCertificateEvent(ctx, EventCertificateIssued, entries...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// SetMaxBatchSize configure maximum number of certificates per batch. Only platform registrar allowed.
+func (s *SmartContract) SetMaxBatchSize(ctx contractapi.TransactionContextInterface, maxBatchSize int) error {
+	err := assertRegistrar(ctx)
+	if err != nil {
+		return err
+	}
+
+	if maxBatchSize <= 0 {
+		return fmt.Errorf("Maximum batch size must be greater than zero")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{configBatchSize})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, []byte(strconv.Itoa(maxBatchSize)))
+}
+
+// GetMaxBatchSize returns maximum number of certificates per batch
+func (s *SmartContract) GetMaxBatchSize(ctx contractapi.TransactionContextInterface) (int, error) {
+	return getMaxBatchSize(ctx)
+}
+
+func getMaxBatchSize(ctx contractapi.TransactionContextInterface) (int, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{configBatchSize})
+	if err != nil {
+		return 0, err
+	}
+
+	dataBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if dataBytes == nil {
+		return DefaultMaxBatchSize, nil
+	}
+
+	return strconv.Atoi(string(dataBytes))
+}
+
+func batchErrors(result *BatchIssueResult) string {
+	message := ""
+	for i, item := range result.Items {
+		if item.Error == "" {
+			continue
+		}
+		if message != "" {
+			message += "; "
+		}
+		message += fmt.Sprintf("[%d] %s: %s", i, item.CertificateKey, item.Error)
+	}
+	return message
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// canonicalJSON serializes value into JSON Canonicalization Scheme (RFC 8785):
+// object keys sorted by UTF-16 code units, no whitespace, minimal string escaping
+// and numbers formatted as ECMAScript Number.prototype.toString.
+func canonicalJSON(value interface{}) ([]byte, error) {
+	// Normalize structs and typed values into generic JSON values
+	dataBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(dataBytes))
+	decoder.UseNumber()
+
+	var generic interface{}
+	err = decoder.Decode(&generic)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := new(bytes.Buffer)
+	err = writeCanonical(buffer, generic)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func writeCanonical(buffer *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buffer.WriteString("null")
+
+	case bool:
+		buffer.WriteString(strconv.FormatBool(v))
+
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("Invalid number %s. %s", v.String(), err.Error())
+		}
+		number, err := canonicalNumber(f)
+		if err != nil {
+			return err
+		}
+		buffer.WriteString(number)
+
+	case string:
+		writeCanonicalString(buffer, v)
+
+	case []interface{}:
+		buffer.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			err := writeCanonical(buffer, item)
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buffer.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			writeCanonicalString(buffer, key)
+			buffer.WriteByte(':')
+			err := writeCanonical(buffer, v[key])
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+
+	default:
+		return fmt.Errorf("Unsupported JSON value type %T", value)
+	}
+
+	return nil
+}
+
+// canonicalNumber formats IEEE 754 double as ECMAScript Number.prototype.toString
+func canonicalNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("Number %v is not allowed in JSON", f)
+	}
+
+	if f == 0 {
+		return "0", nil
+	}
+
+	format := byte('f')
+	abs := math.Abs(f)
+	if abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+
+	number := strconv.FormatFloat(f, format, -1, 64)
+	if format == 'e' {
+		// Go pads exponent into two digits (1e-07), ECMAScript does not (1e-7)
+		i := strings.IndexByte(number, 'e')
+		exponent := strings.TrimLeft(number[i+2:], "0")
+		number = number[:i+2] + exponent
+	}
+
+	return number, nil
+}
+
+func writeCanonicalString(buffer *bytes.Buffer, s string) {
+	buffer.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buffer, `\u%04x`, r)
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteByte('"')
+}
+
+// lessUTF16 compares strings by UTF-16 code units as required for sorting object keys
+func lessUTF16(a, b string) bool {
+	ua := utf16.Encode([]rune(a))
+	ub := utf16.Encode([]rune(b))
+
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+
+	return len(ua) < len(ub)
+}
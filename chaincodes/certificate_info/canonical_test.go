@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// Test vectors of RFC 8785 section 3.2.2, 3.2.3 and appendix B
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "values",
+			input: `{
+				"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+				"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+				"literals": [null, true, false]
+			}`,
+			expected: "{\"literals\":[null,true,false],\"numbers\":[333333333.3333333,1e+30,4.5,0.002,1e-27]," +
+				"\"string\":\"\u20ac$\\u000f\\nA'B\\\"\\\\\\\\\\\"/\"}",
+		},
+		{
+			name: "key sorting by UTF-16 code units",
+			input: `{
+				"\u20ac": "Euro Sign",
+				"\r": "Carriage Return",
+				"\ufb33": "Hebrew Letter Dalet With Dagesh",
+				"1": "One",
+				"\ud83d\ude00": "Emoji: Grinning Face",
+				"\u0080": "Control",
+				"\u00f6": "Latin Small Letter O With Diaeresis"
+			}`,
+			expected: "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\"," +
+				"\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\"," +
+				"\"\U0001f600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{
+			name: "weird keys and values",
+			input: `{
+				"\u20ac": "Euro Sign",
+				"\r": "Carriage Return",
+				"\u000a": "Newline",
+				"1": "One",
+				"\u0080": "Control\u007f",
+				"\ud83d\ude02": "Smiley",
+				"\u00f6": "Latin Small Letter O With Diaeresis",
+				"\ufb33": "Hebrew Letter Dalet With Dagesh",
+				"</script>": "Browser Challenge"
+			}`,
+			expected: "{\"\\n\":\"Newline\",\"\\r\":\"Carriage Return\",\"1\":\"One\",\"</script>\":\"Browser Challenge\"," +
+				"\"\u0080\":\"Control\u007f\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\"," +
+				"\"\u20ac\":\"Euro Sign\",\"\U0001f602\":\"Smiley\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{
+			name:     "nested objects and arrays",
+			input:    `{"b": [{"z": 1, "a": 2}, []], "a": {}}`,
+			expected: `{"a":{},"b":[{"a":2,"z":1},[]]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			canonical, err := canonicalJSON(json.RawMessage(test.input))
+			if err != nil {
+				t.Fatalf("Failed to canonicalize. %s", err.Error())
+			}
+
+			if string(canonical) != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, string(canonical))
+			}
+		})
+	}
+}
+
+// Number test vectors of RFC 8785 appendix B, IEEE 754 bits and expected serialization
+func TestCanonicalNumber(t *testing.T) {
+	tests := []struct {
+		bits     uint64
+		expected string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555554, "333333333.33333325"},
+		{0x41b3de4355555555, "333333333.3333333"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0x41b3de4355555557, "333333333.33333343"},
+		{0xbecbf647612f3696, "-0.0000033333333333333333"},
+		{0x43143ff3c1cb0959, "1424953923781206.2"},
+	}
+
+	for _, test := range tests {
+		number, err := canonicalNumber(math.Float64frombits(test.bits))
+		if err != nil {
+			t.Errorf("Failed to format %016x. %s", test.bits, err.Error())
+			continue
+		}
+
+		if number != test.expected {
+			t.Errorf("Expected %016x formatted as %s, got %s", test.bits, test.expected, number)
+		}
+	}
+}
+
+func TestCanonicalNumberRejectsNaNAndInfinity(t *testing.T) {
+	for _, bits := range []uint64{0x7fffffffffffffff, 0x7ff0000000000000} {
+		_, err := canonicalNumber(math.Float64frombits(bits))
+		if err == nil {
+			t.Errorf("Expected %016x to be rejected", bits)
+		}
+	}
+}
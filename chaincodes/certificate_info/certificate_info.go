@@ -5,8 +5,12 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
@@ -17,40 +21,201 @@ type SmartContract struct {
 	contractapi.Contract
 }
 
+const (
+	// IssuerIdAttribute is the X.509 attribute (registered through Fabric CA) binding an enrolled identity to issuer id
+	IssuerIdAttribute = "issuer_id"
+	// IssuerRegistryChaincode is the chaincode name of issuer registry on the same channel
+	IssuerRegistryChaincode = "issuer_registry"
+	// CertificateTemplateChaincode is the chaincode name of certificate template on the same channel
+	CertificateTemplateChaincode = "certificate_template"
+	// TokenRegistryChaincode is the chaincode name of token registry on the same channel
+	TokenRegistryChaincode = "token_registry"
+	// MaxTokenRevocationBatchSize is maximum number of tokens of each certificate version revoked by RevokeCertificate
+	MaxTokenRevocationBatchSize = 500
+	// RegistrarAttribute is the X.509 attribute designating platform registrar allowed to revoke any certificate
+	RegistrarAttribute = "certificate_registrar"
+
+	IssuerActive = "ACTIVE"
+
+	// MaxPageSize is maximum number of records per page of paginated query
+	MaxPageSize = 1000
+)
+
+// Revocation reason codes accepted by RevokeCertificate
+const (
+	RevocationFraud         = "FRAUD"
+	RevocationClericalError = "CLERICAL_ERROR"
+	RevocationSuperseded    = "SUPERSEDED"
+	RevocationOther         = "OTHER"
+)
+
 // certKey: certificate_id (uuid) use to issue certificate
-// certSignature: digital signature of certificate signed by issuer (academic institution)
+// certSignature: digital signature of certificate signed by issuer (academic institution), base64 encoded
+// signatureKeyId: key id of issuer public key that verified certSignature
 // templateRef: template key reference (uuid) use to generate certificate
 // courseName: primary course name
 // moduleName: secondary course name (module name or course name (ii))
-// certificateHolder: name of certificate holder
-// email: email of certificate holder
-// isRevoked: revocation status of certificate
+// certificateHolder: name of certificate holder (only certificates issued before holder data moved into private data)
+// email: email of certificate holder (only certificates issued before holder data moved into private data)
+// piiHash: salted hash of certificate holder data (see CertificatePii)
+// piiCollection: private data collection storing certificate holder data
+// status: lifecycle status of certificate (ACTIVE, SUSPENDED, REVOKED, SUPERSEDED, EXPIRED)
+// statusListIndex: index of certificate in status list of issuer (zero if issued before status list introduced)
+// suspension: suspension details, only present while certificate suspended
+// revocation: revocation details, only present once certificate revoked
+// erasure: erasure tombstone, only present once certificate holder data erased
+// supersedes: key of certificate replaced by this certificate (see ReissueCertificate)
+// supersededBy: key of certificate replacing this certificate
+// supersededAt: transaction timestamp of re-issuance (unix seconds)
 // issuerId: identity of reference issuer on blockchain
 // issuerName: name of academic institution
+// issuerMsp: MSP ID of organization that submitted the issuance
 // issuedAt: actual date certificate issued physically
 // extras: additional details of the certificates (associated course attributes)
 
 // CertificateRecord describes basic details of certificate record detail
 type CertificateRecord struct {
 	CertificateSignature string      `json:"certificate_signature"`
+	SignatureKeyId       string      `json:"signature_key_id"`
 	TemplateRef          string      `json:"template_ref"`
 	CourseName           string      `json:"course_name"`
 	ModuleName           string      `json:"module_name"`
-	CertificateHolder    string      `json:"certificate_holder"`
-	Email                string      `json:"email"`
-	IsRevoked            bool        `json:"is_revoked"`
+	CertificateHolder    string      `json:"certificate_holder,omitempty"`
+	Email                string      `json:"email,omitempty"`
+	PiiHash              string      `json:"pii_hash,omitempty"`
+	PiiCollection        string      `json:"pii_collection,omitempty"`
+	Status               string      `json:"status"`
+	StatusListIndex      int64       `json:"status_list_index"`
+	Suspension           *Suspension `json:"suspension,omitempty"`
+	Revocation           *Revocation `json:"revocation,omitempty"`
+	Erasure              *Erasure    `json:"erasure,omitempty"`
+	Supersedes           string      `json:"supersedes,omitempty"`
+	SupersededBy         string      `json:"superseded_by,omitempty"`
+	SupersededAt         int64       `json:"superseded_at,omitempty"`
 	IssuerId             string      `json:"issuer_id"`
 	IssuerName           string      `json:"issuer_name"`
+	IssuerMsp            string      `json:"issuer_msp"`
 	IssuedAt             string      `json:"issued_at"`
 	Extras               interface{} `json:"extras"`
 }
 
+// reasonCode: revocation reason code (FRAUD, CLERICAL_ERROR, SUPERSEDED, OTHER)
+// note: free-text note of revocation
+// revokedBy: client identity id of revoker
+// revokerMsp: MSP ID of revoker
+// revokedAt: transaction timestamp of revocation (unix seconds)
+
+// Revocation describes why and when certificate revoked
+type Revocation struct {
+	ReasonCode string `json:"reason_code"`
+	Note       string `json:"note"`
+	RevokedBy  string `json:"revoked_by"`
+	RevokerMsp string `json:"revoker_msp"`
+	RevokedAt  int64  `json:"revoked_at"`
+}
+
+// note: free-text note of suspension
+// suspendedBy: client identity id of suspender
+// suspenderMsp: MSP ID of suspender
+// suspendedAt: transaction timestamp of suspension (unix seconds)
+
+// Suspension describes why and when certificate suspended
+type Suspension struct {
+	Note         string `json:"note"`
+	SuspendedBy  string `json:"suspended_by"`
+	SuspenderMsp string `json:"suspender_msp"`
+	SuspendedAt  int64  `json:"suspended_at"`
+}
+
+// CertificatePayload describes certificate to be issued, fields follow IssueCertificate arguments.
+// Supersedes is only set by ReissueCertificate.
+type CertificatePayload struct {
+	CertificateKey       string      `json:"certificate_key"`
+	CertificateSignature string      `json:"certificate_signature"`
+	TemplateRef          string      `json:"template_ref"`
+	CourseName           string      `json:"course_name"`
+	ModuleName           string      `json:"module_name,omitempty"`
+	IssuerId             string      `json:"issuer_id"`
+	IssuerName           string      `json:"issuer_name,omitempty"`
+	IssuedAt             string      `json:"issued_at"`
+	Extras               interface{} `json:"extras,omitempty"`
+	Supersedes           string      `json:"-"`
+}
+
+// erasedBy: client identity id who requested erasure
+// erasureMsp: MSP ID of erasure requester
+// erasedAt: transaction timestamp of erasure (unix seconds)
+
+// Erasure describes tombstone of certificate holder data erased on request (right to erasure)
+type Erasure struct {
+	ErasedBy   string `json:"erased_by"`
+	ErasureMsp string `json:"erasure_msp"`
+	ErasedAt   int64  `json:"erased_at"`
+}
+
+// IssuerRecord describes issuer registered in issuer registry chaincode
+type IssuerRecord struct {
+	MspId               string            `json:"msp_id"`
+	IssuerName          string            `json:"issuer_name"`
+	PublicKeys          []IssuerPublicKey `json:"public_keys"`
+	AccreditationStatus string            `json:"accreditation_status"`
+	Status              string            `json:"status"`
+}
+
+// IssuerPublicKey describes public signing key of issuer registered in issuer registry chaincode
+type IssuerPublicKey struct {
+	KeyId     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+	IsRevoked bool   `json:"is_revoked"`
+}
+
+// TemplateRecord describes certificate template stored in certificate template chaincode
+type TemplateRecord struct {
+	SourceType   string      `json:"source_type"`
+	Version      string      `json:"version"`
+	IssuerId     string      `json:"issuer_id"`
+	IssuerName   string      `json:"issuer_name"`
+	Deprecated   bool        `json:"deprecated"`
+	ExtrasSchema interface{} `json:"extras_schema,omitempty"`
+}
+
+// CertificateDigest describes canonical form of certificate fields signed by issuer and its SHA-256 digest (hex)
+type CertificateDigest struct {
+	CertificateKey string `json:"certificate_key"`
+	Canonical      string `json:"canonical"`
+	Algorithm      string `json:"algorithm"`
+	Digest         string `json:"digest"`
+}
+
+// VerificationResult describes result of certificate verification
+type VerificationResult struct {
+	CertificateKey string   `json:"certificate_key"`
+	Status         string   `json:"status"`
+	SignatureValid bool     `json:"signature_valid"`
+	SignatureKeyId string   `json:"signature_key_id"`
+	KeyRevoked     bool     `json:"key_revoked"`
+	HolderErased   bool     `json:"holder_data_erased"`
+	IssuerActive   bool     `json:"issuer_active"`
+	NotRevoked     bool     `json:"not_revoked"`
+	TemplateExists bool     `json:"template_exists"`
+	Valid          bool     `json:"valid"`
+	Errors         []string `json:"errors"`
+}
+
 // QueryResult structure used for handling result of query
 type QueryResult struct {
 	Key    string             `json:"key"`
 	Record *CertificateRecord `json:"record"`
 }
 
+// PaginatedQueryResult structure used for handling result of paginated query
+type PaginatedQueryResult struct {
+	Records      []*QueryResult `json:"records"`
+	FetchedCount int32          `json:"fetchedCount"`
+	Bookmark     string         `json:"bookmark"`
+}
+
 // HistoryQueryResult used for handling result modification history of certificate
 type HistoryQueryResult struct {
 	Value     *CertificateRecord `json:"value"`
@@ -59,36 +224,161 @@ type HistoryQueryResult struct {
 	IsDelete  bool               `json:"is_delete"`
 }
 
-// IssueCertificate add new certificate into ledger
+// IssueCertificate add new certificate into ledger.
+// Certificate holder data (CertificatePii) is passed in transient map and kept in private data collection of issuer organization.
 func (s *SmartContract) IssueCertificate(ctx contractapi.TransactionContextInterface,
-	certKey, certSignature, templateRef, courseName, moduleName, certHolder, email, issuerId,
+	certKey, certSignature, templateRef, courseName, moduleName, issuerId,
 	issuer, issuedAt string, extras interface{}) error {
 
-	cert, _ := s.QueryCertificate(ctx, certKey)
-	if cert != nil {
-		return fmt.Errorf("Certificate %s already issued", certKey)
+	pii, err := getTransientPii(ctx)
+	if err != nil {
+		return err
 	}
 
-	certificate := CertificateRecord{
+	payload := &CertificatePayload{
+		CertificateKey:       certKey,
 		CertificateSignature: certSignature,
 		TemplateRef:          templateRef,
 		CourseName:           courseName,
 		ModuleName:           moduleName,
-		CertificateHolder:    certHolder,
-		Email:                email,
-		IsRevoked:            false,
 		IssuerId:             issuerId,
 		IssuerName:           issuer,
 		IssuedAt:             issuedAt,
 		Extras:               extras,
 	}
 
-	certificateBytes, err := json.Marshal(certificate)
+	certificate, err := s.prepareCertificate(ctx, payload, pii, newIssuance())
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(certKey, certificateBytes)
+	err = putCertificate(ctx, certKey, certificate)
+	if err != nil {
+		return err
+	}
+
+	err = putCertificatePii(ctx, certKey, certificate, pii)
+	if err != nil {
+		return err
+	}
+
+	err = putCertificateIndexes(ctx, certKey, certificate, pii)
+	if err != nil {
+		return err
+	}
+
+	return setCertificateEvent(ctx, EventCertificateIssued, newEventEntry(certKey, certificate))
+}
+
+// issuance holds state shared by certificates issued within one transaction
+// (state written in the transaction is not visible to GetState until committed)
+type issuance struct {
+	// issuers already authorized within the transaction
+	issuers map[string]*IssuerRecord
+	// next status list index of issuers allocated within the transaction
+	nextIndex map[string]int64
+	// templates already read within the transaction
+	templates map[string]*TemplateRecord
+}
+
+func newIssuance() *issuance {
+	return &issuance{
+		issuers:   map[string]*IssuerRecord{},
+		nextIndex: map[string]int64{},
+		templates: map[string]*TemplateRecord{},
+	}
+}
+
+// prepareCertificate validates issuance payload and holder data, returns certificate record to be written
+func (s *SmartContract) prepareCertificate(ctx contractapi.TransactionContextInterface,
+	payload *CertificatePayload, pii *CertificatePii, tx *issuance) (*CertificateRecord, error) {
+
+	if payload.CertificateKey == "" {
+		return nil, fmt.Errorf("Certificate key must not be empty")
+	}
+
+	_, err := formatIssuanceDate(payload.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	piiHash, err := hashPii(pii)
+	if err != nil {
+		return nil, err
+	}
+
+	issuerRecord, ok := tx.issuers[payload.IssuerId]
+	if !ok {
+		issuerRecord, err = assertIssuerIdentity(ctx, payload.IssuerId)
+		if err != nil {
+			return nil, err
+		}
+		tx.issuers[payload.IssuerId] = issuerRecord
+	}
+
+	if payload.IssuerName != "" && payload.IssuerName != issuerRecord.IssuerName {
+		return nil, fmt.Errorf("Issuer name %s does not match registered name of issuer %s", payload.IssuerName, payload.IssuerId)
+	}
+
+	cert, _ := s.QueryCertificate(ctx, payload.CertificateKey)
+	if cert != nil {
+		return nil, fmt.Errorf("Certificate %s already issued", payload.CertificateKey)
+	}
+
+	template, ok := tx.templates[payload.TemplateRef]
+	if !ok {
+		template, err = queryTemplate(ctx, payload.TemplateRef)
+		if err != nil {
+			return nil, err
+		}
+		tx.templates[payload.TemplateRef] = template
+	}
+
+	if template.IssuerId != payload.IssuerId {
+		return nil, fmt.Errorf("Template %s does not belong to issuer %s", payload.TemplateRef, payload.IssuerId)
+	}
+
+	if template.Deprecated {
+		return nil, fmt.Errorf("Template %s is deprecated", payload.TemplateRef)
+	}
+
+	err = validateExtras(payload.TemplateRef, template, payload.Extras)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate := &CertificateRecord{
+		CertificateSignature: payload.CertificateSignature,
+		TemplateRef:          payload.TemplateRef,
+		CourseName:           payload.CourseName,
+		ModuleName:           payload.ModuleName,
+		PiiHash:              piiHash,
+		PiiCollection:        piiCollection(issuerRecord.MspId),
+		Status:               StatusActive,
+		IssuerId:             payload.IssuerId,
+		IssuerName:           issuerRecord.IssuerName,
+		IssuerMsp:            issuerRecord.MspId,
+		IssuedAt:             payload.IssuedAt,
+		Extras:               payload.Extras,
+		Supersedes:           payload.Supersedes,
+	}
+
+	message, err := canonicalCertificate(payload.CertificateKey, certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate.SignatureKeyId, err = verifyIssuerSignature(issuerRecord, payload.CertificateSignature, message)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate.StatusListIndex, err = tx.allocateStatusListIndex(ctx, payload.IssuerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return certificate, nil
 }
 
 // QueryCertificate returns the certificate stored in the world state with given id
@@ -111,29 +401,243 @@ func (s *SmartContract) QueryCertificate(ctx contractapi.TransactionContextInter
 	return certificate, nil
 }
 
-// RevokeCertificate revoke certificate that already issued by certKey
-func (s *SmartContract) RevokeCertificate(ctx contractapi.TransactionContextInterface, certKey string) error {
+// CertificateExists returns true when certificate with given id exists in the world state
+func (s *SmartContract) CertificateExists(ctx contractapi.TransactionContextInterface, certKey string) (bool, error) {
+	certificateBytes, err := ctx.GetStub().GetState(certKey)
+	if err != nil {
+		return false, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	return certificateBytes != nil, nil
+}
+
+// VerifyCertificate re-checks certificate signature against issuer public key,
+// issuer status, certificate status and template existence
+func (s *SmartContract) VerifyCertificate(ctx contractapi.TransactionContextInterface, certKey string) (*VerificationResult, error) {
+	certificate, err := s.QueryCertificate(ctx, certKey)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VerificationResult{
+		CertificateKey: certKey,
+		Status:         certificate.Status,
+		SignatureKeyId: certificate.SignatureKeyId,
+		NotRevoked:     certificate.Status != StatusRevoked,
+		HolderErased:   certificate.Erasure != nil,
+		Errors:         []string{},
+	}
+
+	if certificate.Status != StatusActive {
+		result.Errors = append(result.Errors, fmt.Sprintf("Certificate status is %s", certificate.Status))
+	}
+
+	if certificate.SupersededBy != "" {
+		result.Errors = append(result.Errors, fmt.Sprintf("Certificate superseded by %s", certificate.SupersededBy))
+	}
+
+	issuer, err := queryIssuer(ctx, certificate.IssuerId)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		result.IssuerActive = issuer.Status == IssuerActive
+		if !result.IssuerActive {
+			result.Errors = append(result.Errors, fmt.Sprintf("Issuer status is %s", issuer.Status))
+		}
+
+		// Signature of certificate issued before holder data moved into private data
+		// covers erased holder name and email, it can no longer be re-checked
+		if result.HolderErased && certificate.PiiHash == "" {
+			result.Errors = append(result.Errors, "Holder data erased, signature cannot be re-checked")
+		} else {
+			err = verifyCertificateSignature(certKey, certificate, issuer, result)
+			if err != nil {
+				result.Errors = append(result.Errors, err.Error())
+			}
+		}
+	}
+
+	_, err = queryTemplate(ctx, certificate.TemplateRef)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		result.TemplateExists = true
+	}
+
+	result.Valid = len(result.Errors) == 0
+
+	return result, nil
+}
+
+// GetCertificateDigest returns canonical JSON (RFC 8785) of certificate fields signed by issuer and its digest
+func (s *SmartContract) GetCertificateDigest(ctx contractapi.TransactionContextInterface, certKey string) (*CertificateDigest, error) {
 	certificate, err := s.QueryCertificate(ctx, certKey)
+	if err != nil {
+		return nil, err
+	}
+
+	canonical, err := canonicalCertificate(certKey, certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(canonical)
+
+	return &CertificateDigest{
+		CertificateKey: certKey,
+		Canonical:      string(canonical),
+		Algorithm:      "SHA-256",
+		Digest:         hex.EncodeToString(digest[:]),
+	}, nil
+}
+
+// RevokeCertificate revoke certificate that already issued by certKey, and tokens of the certificate and its previous
+// versions in token registry in the same transaction (at most MaxTokenRevocationBatchSize tokens per version, the rest
+// stay blocked until revoked by RevokeTokensForCertificate of token registry).
+// Only the original issuer or platform registrar allowed, reasonCode is mandatory.
+func (s *SmartContract) RevokeCertificate(ctx contractapi.TransactionContextInterface, certKey, reasonCode, note string) error {
+	if !isValidRevocationReason(reasonCode) {
+		return fmt.Errorf("Invalid revocation reason code: %s", reasonCode)
+	}
+
+	certificate, err := s.prepareStatusChange(ctx, certKey, StatusRevoked)
 	if err != nil {
 		return err
 	}
 
-	if certificate.IsRevoked {
-		return fmt.Errorf("Certificate %s already revoked", certKey)
+	revokedBy, revokerMsp, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
 	}
 
-	certificate.IsRevoked = true
-	certificateBytes, err := json.Marshal(certificate)
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(certKey, certificateBytes)
+	certificate.Status = StatusRevoked
+	certificate.Suspension = nil
+	certificate.Revocation = &Revocation{
+		ReasonCode: reasonCode,
+		Note:       note,
+		RevokedBy:  revokedBy,
+		RevokerMsp: revokerMsp,
+		RevokedAt:  txTimestamp.Seconds,
+	}
+
+	err = putCertificateStatus(ctx, certKey, certificate)
+	if err != nil {
+		return err
+	}
+
+	err = s.revokeCertificateTokens(ctx, certKey, certificate)
+	if err != nil {
+		return err
+	}
+
+	return setCertificateEvent(ctx, EventCertificateRevoked, newEventEntry(certKey, certificate))
+}
+
+// SuspendCertificate temporarily suspend active certificate (example: during disciplinary investigation).
+// Only the original issuer or platform registrar allowed.
+func (s *SmartContract) SuspendCertificate(ctx contractapi.TransactionContextInterface, certKey, note string) error {
+	certificate, err := s.prepareStatusChange(ctx, certKey, StatusSuspended)
+	if err != nil {
+		return err
+	}
+
+	suspendedBy, suspenderMsp, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	certificate.Status = StatusSuspended
+	certificate.Suspension = &Suspension{
+		Note:         note,
+		SuspendedBy:  suspendedBy,
+		SuspenderMsp: suspenderMsp,
+		SuspendedAt:  txTimestamp.Seconds,
+	}
+
+	err = putCertificateStatus(ctx, certKey, certificate)
+	if err != nil {
+		return err
+	}
+
+	return setCertificateEvent(ctx, EventCertificateSuspended, newEventEntry(certKey, certificate))
+}
+
+// ReinstateCertificate reactivate suspended certificate.
+// Only the original issuer or platform registrar allowed.
+func (s *SmartContract) ReinstateCertificate(ctx contractapi.TransactionContextInterface, certKey string) error {
+	certificate, err := s.prepareStatusChange(ctx, certKey, StatusActive)
+	if err != nil {
+		return err
+	}
+
+	certificate.Status = StatusActive
+	certificate.Suspension = nil
+
+	err = putCertificateStatus(ctx, certKey, certificate)
+	if err != nil {
+		return err
+	}
+
+	return setCertificateEvent(ctx, EventCertificateReinstated, newEventEntry(certKey, certificate))
+}
+
+// ExpireCertificate mark certificate as expired.
+// Only the original issuer or platform registrar allowed.
+func (s *SmartContract) ExpireCertificate(ctx contractapi.TransactionContextInterface, certKey string) error {
+	certificate, err := s.prepareStatusChange(ctx, certKey, StatusExpired)
+	if err != nil {
+		return err
+	}
+
+	certificate.Status = StatusExpired
+	certificate.Suspension = nil
+
+	err = putCertificateStatus(ctx, certKey, certificate)
+	if err != nil {
+		return err
+	}
+
+	return setCertificateEvent(ctx, EventCertificateExpired, newEventEntry(certKey, certificate))
+}
+
+// prepareStatusChange read certificate and assert the caller allowed to change its status to next
+func (s *SmartContract) prepareStatusChange(ctx contractapi.TransactionContextInterface, certKey, next string) (*CertificateRecord, error) {
+	certificate, err := s.QueryCertificate(ctx, certKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = assertStatusTransition(certKey, certificate.Status, next)
+	if err != nil {
+		return nil, err
+	}
+
+	err = assertIssuerOrRegistrar(ctx, certificate.IssuerId, certificate.IssuerMsp)
+	if err != nil {
+		return nil, err
+	}
+
+	return certificate, nil
 }
 
 // QueryRecords uses a query string to perform a query for certificates.
-// Query string matching state database syntax is passed in and executed as is.
+// Query string matching state database syntax is passed in and executed as is. Only query admin allowed,
+// use QueryRecordsByFilter otherwise.
 func (s *SmartContract) QueryRecords(ctx contractapi.TransactionContextInterface, queryString string) ([]*CertificateRecord, error) {
+	if err := assertQueryAdmin(ctx); err != nil {
+		return nil, err
+	}
+
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, err
@@ -143,23 +647,73 @@ func (s *SmartContract) QueryRecords(ctx contractapi.TransactionContextInterface
 	return constructQueryResponseFromIterator(resultsIterator)
 }
 
+// QueryRecordsWithPagination uses a query string to perform a query for certificates page by page.
+// Pass bookmark returned by previous page to fetch the next page, empty bookmark fetch the first page.
+// Only query admin allowed, use QueryRecordsByFilter otherwise.
+func (s *SmartContract) QueryRecordsWithPagination(ctx contractapi.TransactionContextInterface,
+	queryString string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+
+	if err := assertQueryAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 || pageSize > MaxPageSize {
+		return nil, fmt.Errorf("Page size must be between 1 and %d", MaxPageSize)
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	records, err := constructQueryResultsFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:      records,
+		FetchedCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:     responseMetadata.Bookmark,
+	}, nil
+}
+
 func constructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorInterface) ([]*CertificateRecord, error) {
+	results, err := constructQueryResultsFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
 	records := []*CertificateRecord{}
+	for _, result := range results {
+		records = append(records, result.Record)
+	}
+
+	return records, nil
+}
+
+func constructQueryResultsFromIterator(resultsIterator shim.StateQueryIteratorInterface) ([]*QueryResult, error) {
+	results := []*QueryResult{}
 
 	for resultsIterator.HasNext() {
 		queryResult, err := resultsIterator.Next()
 		if err != nil {
 			return nil, err
 		}
+		// Skip cohort and config entries stored under composite keys
+		if strings.HasPrefix(queryResult.Key, compositeKeyNamespace) {
+			continue
+		}
 		record := CertificateRecord{}
 		err = json.Unmarshal(queryResult.Value, &record)
 		if err != nil {
 			return nil, err
 		}
-		records = append(records, &record)
+		results = append(results, &QueryResult{Key: queryResult.Key, Record: &record})
 	}
 
-	return records, nil
+	return results, nil
 }
 
 // GetHistoryForKey get modification history of certificate
@@ -200,6 +754,233 @@ func (s *SmartContract) GetHistoryForKey(ctx contractapi.TransactionContextInter
 	return results, nil
 }
 
+func putCertificate(ctx contractapi.TransactionContextInterface, certKey string, certificate *CertificateRecord) error {
+	certificateBytes, err := json.Marshal(certificate)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(certKey, certificateBytes)
+}
+
+// getClientIdentity returns id and MSP ID of the submitting client
+func getClientIdentity(ctx contractapi.TransactionContextInterface) (string, string, error) {
+	id, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to read client identity. %s", err.Error())
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	return id, mspId, nil
+}
+
+// assertIssuerIdentity verifies the submitting client is enrolled on behalf of issuerId
+// and the issuer is active in issuer registry under the client MSP
+func assertIssuerIdentity(ctx contractapi.TransactionContextInterface, issuerId string) (*IssuerRecord, error) {
+	if issuerId == "" {
+		return nil, fmt.Errorf("Issuer id must not be empty")
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	clientIssuerId, found, err := ctx.GetClientIdentity().GetAttributeValue(IssuerIdAttribute)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read client attribute %s. %s", IssuerIdAttribute, err.Error())
+	}
+
+	if !found {
+		return nil, fmt.Errorf("Client identity of %s is not registered as issuer", mspId)
+	}
+
+	if clientIssuerId != issuerId {
+		return nil, fmt.Errorf("Client identity of %s is not authorized to act on behalf of issuer %s", mspId, issuerId)
+	}
+
+	issuer, err := queryIssuer(ctx, issuerId)
+	if err != nil {
+		return nil, err
+	}
+
+	if issuer.MspId != mspId {
+		return nil, fmt.Errorf("Issuer %s is not registered under %s", issuerId, mspId)
+	}
+
+	if issuer.Status != IssuerActive {
+		return nil, fmt.Errorf("Issuer %s is not active. Status: %s", issuerId, issuer.Status)
+	}
+
+	return issuer, nil
+}
+
+// assertRegistrar verifies the submitting client is platform registrar
+func assertRegistrar(ctx contractapi.TransactionContextInterface) error {
+	return assertPlatformAttribute(ctx, RegistrarAttribute, "platform registrar")
+}
+
+// assertPlatformAttribute verifies the submitting client is platform organization identity with attribute set to true
+func assertPlatformAttribute(ctx contractapi.TransactionContextInterface, attribute, role string) error {
+	platformMspId, err := queryPlatformMspId(ctx)
+	if err != nil {
+		return err
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	if mspId != platformMspId {
+		return fmt.Errorf("Client identity of %s is not %s", mspId, role)
+	}
+
+	err = ctx.GetClientIdentity().AssertAttributeValue(attribute, "true")
+	if err != nil {
+		return fmt.Errorf("Client identity is not %s. %s", role, err.Error())
+	}
+
+	return nil
+}
+
+// assertIssuerOrRegistrar verifies the submitting client is platform registrar,
+// or enrolled on behalf of issuerId under issuerMsp
+func assertIssuerOrRegistrar(ctx contractapi.TransactionContextInterface, issuerId, issuerMsp string) error {
+	if assertRegistrar(ctx) == nil {
+		return nil
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	clientIssuerId, found, err := ctx.GetClientIdentity().GetAttributeValue(IssuerIdAttribute)
+	if err != nil {
+		return fmt.Errorf("Failed to read client attribute %s. %s", IssuerIdAttribute, err.Error())
+	}
+
+	if !found || clientIssuerId != issuerId || mspId != issuerMsp {
+		return fmt.Errorf("Client identity of %s is not authorized to act on behalf of issuer %s", mspId, issuerId)
+	}
+
+	return nil
+}
+
+func isValidRevocationReason(reasonCode string) bool {
+	switch reasonCode {
+	case RevocationFraud, RevocationClericalError, RevocationSuperseded, RevocationOther:
+		return true
+	}
+	return false
+}
+
+// verifyCertificateSignature verifies certificate signature against issuer key used at issuance and fill the result
+func verifyCertificateSignature(certKey string, certificate *CertificateRecord, issuer *IssuerRecord, result *VerificationResult) error {
+	message, err := canonicalCertificate(certKey, certificate)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range issuer.PublicKeys {
+		if key.KeyId != certificate.SignatureKeyId {
+			continue
+		}
+
+		result.KeyRevoked = key.IsRevoked
+		if key.IsRevoked {
+			return fmt.Errorf("Signature key %s has been revoked", key.KeyId)
+		}
+
+		err = verifySignature(key.Algorithm, key.PublicKey, certificate.CertificateSignature, message)
+		if err != nil {
+			return err
+		}
+
+		result.SignatureValid = true
+		return nil
+	}
+
+	return fmt.Errorf("Signature key %s does not exist for issuer %s", certificate.SignatureKeyId, certificate.IssuerId)
+}
+
+// revokeCertificateTokens revoke tokens of certificate and of every version it supersedes in token registry chaincode,
+// since tokens keep certificate id of the version they were issued for
+func (s *SmartContract) revokeCertificateTokens(ctx contractapi.TransactionContextInterface, certKey string, certificate *CertificateRecord) error {
+	for i := 0; ; i++ {
+		args := [][]byte{[]byte("RevokeTokensForCertificate"), []byte(certKey), []byte(strconv.Itoa(MaxTokenRevocationBatchSize))}
+		response := ctx.GetStub().InvokeChaincode(TokenRegistryChaincode, args, "")
+		if response.Status != shim.OK {
+			return fmt.Errorf("Failed to revoke tokens in token registry. %s", response.Message)
+		}
+
+		if certificate.Supersedes == "" {
+			return nil
+		}
+
+		if i == maxSupersedeChainLength {
+			return fmt.Errorf("Certificate %s supersede chain exceeds %d re-issuances", certKey, maxSupersedeChainLength)
+		}
+
+		var err error
+		certKey = certificate.Supersedes
+		certificate, err = s.QueryCertificate(ctx, certKey)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// queryTemplate read template from certificate template chaincode
+func queryTemplate(ctx contractapi.TransactionContextInterface, templateKey string) (*TemplateRecord, error) {
+	args := [][]byte{[]byte("QueryTemplate"), []byte(templateKey)}
+	response := ctx.GetStub().InvokeChaincode(CertificateTemplateChaincode, args, "")
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("Failed to query certificate template. %s", response.Message)
+	}
+
+	template := new(TemplateRecord)
+	err := json.Unmarshal(response.Payload, template)
+	if err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// queryIssuer read issuer from issuer registry chaincode
+func queryIssuer(ctx contractapi.TransactionContextInterface, issuerId string) (*IssuerRecord, error) {
+	args := [][]byte{[]byte("QueryIssuer"), []byte(issuerId)}
+	response := ctx.GetStub().InvokeChaincode(IssuerRegistryChaincode, args, "")
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("Failed to query issuer registry. %s", response.Message)
+	}
+
+	issuer := new(IssuerRecord)
+	err := json.Unmarshal(response.Payload, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return issuer, nil
+}
+
+// queryPlatformMspId read MSP ID of platform organization from issuer registry chaincode
+func queryPlatformMspId(ctx contractapi.TransactionContextInterface) (string, error) {
+	args := [][]byte{[]byte("GetPlatformMspId")}
+	response := ctx.GetStub().InvokeChaincode(IssuerRegistryChaincode, args, "")
+	if response.Status != shim.OK {
+		return "", fmt.Errorf("Failed to query issuer registry. %s", response.Message)
+	}
+
+	return string(response.Payload), nil
+}
+
 func main() {
 	chaincode, err := contractapi.NewChaincode(new(SmartContract))
 
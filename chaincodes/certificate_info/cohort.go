@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// compositeKeyNamespace is the prefix of keys created by CreateCompositeKey
+	compositeKeyNamespace = "\x00"
+
+	cohortObjectType     = "cohort"
+	cohortLeafObjectType = "cohort_revoked_leaf"
+	maxMerkleProofLength = 64
+	merkleProofLeft      = "left"
+	merkleProofRight     = "right"
+)
+
+// merkleNodePrefix separates internal node hash from leaf hash (second preimage protection).
+// Leaves are hashed off-chain as SHA-256(0x00 || leaf data).
+const merkleNodePrefix byte = 0x01
+
+// merkleRoot: hex encoded SHA-256 merkle root of cohort certificates
+// issuerId: identity of reference issuer on blockchain
+// issuerName: name of academic institution
+// issuerMsp: MSP ID of organization that anchored the cohort
+// count: number of certificates (leaves) in the cohort
+// anchoredAt: transaction timestamp of anchoring (unix seconds)
+
+// CohortRecord describes merkle root anchored for large certificate cohort
+type CohortRecord struct {
+	MerkleRoot string `json:"merkle_root"`
+	IssuerId   string `json:"issuer_id"`
+	IssuerName string `json:"issuer_name"`
+	IssuerMsp  string `json:"issuer_msp"`
+	Count      int64  `json:"count"`
+	AnchoredAt int64  `json:"anchored_at"`
+}
+
+// MerkleProofStep describes sibling hash (hex encoded) and its position on the path from leaf to root
+type MerkleProofStep struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"`
+}
+
+// InclusionResult describes result of merkle inclusion proof verification
+type InclusionResult struct {
+	CohortId   string      `json:"cohort_id"`
+	LeafHash   string      `json:"leaf_hash"`
+	Included   bool        `json:"included"`
+	Revoked    bool        `json:"revoked"`
+	Revocation *Revocation `json:"revocation,omitempty"`
+	Valid      bool        `json:"valid"`
+}
+
+// AnchorCohort anchor merkle root of certificate cohort instead of storing every certificate.
+// Leaves are SHA-256(0x00 || leaf data) hashed off-chain by issuer, tree nodes are SHA-256(0x01 || left || right).
+// Last node of level with odd number of nodes is paired with itself, so every proof has ceil(log2(count)) steps.
+func (s *SmartContract) AnchorCohort(ctx contractapi.TransactionContextInterface,
+	cohortId, merkleRoot, issuerId string, count int64) error {
+
+	issuer, err := assertIssuerIdentity(ctx, issuerId)
+	if err != nil {
+		return err
+	}
+
+	if cohortId == "" {
+		return fmt.Errorf("Cohort id must not be empty")
+	}
+
+	if count <= 0 {
+		return fmt.Errorf("Cohort count must be greater than zero")
+	}
+
+	if merkleProofLength(count) > maxMerkleProofLength {
+		return fmt.Errorf("Cohort count exceeds merkle proof of %d steps", maxMerkleProofLength)
+	}
+
+	_, err = decodeHash(merkleRoot)
+	if err != nil {
+		return err
+	}
+
+	cohort, _ := s.QueryCohort(ctx, cohortId)
+	if cohort != nil {
+		return fmt.Errorf("Cohort %s already anchored", cohortId)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	cohort = &CohortRecord{
+		MerkleRoot: strings.ToLower(merkleRoot),
+		IssuerId:   issuerId,
+		IssuerName: issuer.IssuerName,
+		IssuerMsp:  issuer.MspId,
+		Count:      count,
+		AnchoredAt: txTimestamp.Seconds,
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(cohortObjectType, []string{cohortId})
+	if err != nil {
+		return err
+	}
+
+	dataBytes, err := json.Marshal(cohort)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, dataBytes)
+	if err != nil {
+		return err
+	}
+
+	return setCohortEvent(ctx, EventCohortAnchored, newCohortEventEntry(cohortId, cohort))
+}
+
+// QueryCohort returns the cohort anchored with given id
+func (s *SmartContract) QueryCohort(ctx contractapi.TransactionContextInterface, cohortId string) (*CohortRecord, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(cohortObjectType, []string{cohortId})
+	if err != nil {
+		return nil, err
+	}
+
+	dataBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if dataBytes == nil {
+		return nil, fmt.Errorf("Cohort %s does not exist", cohortId)
+	}
+
+	cohort := new(CohortRecord)
+	err = json.Unmarshal(dataBytes, cohort)
+	if err != nil {
+		return nil, err
+	}
+
+	return cohort, nil
+}
+
+// VerifyInclusion checks merkle inclusion proof of leafHash against anchored cohort root and leaf revocation
+func (s *SmartContract) VerifyInclusion(ctx contractapi.TransactionContextInterface,
+	cohortId, leafHash string, proof []MerkleProofStep) (*InclusionResult, error) {
+
+	// Revoked leaves are keyed by lower case hex
+	leafHash = strings.ToLower(leafHash)
+
+	cohort, err := s.QueryCohort(ctx, cohortId)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := decodeHash(cohort.MerkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	// Shorter proof would verify internal node as leaf
+	if len(proof) != merkleProofLength(cohort.Count) {
+		return nil, fmt.Errorf("Merkle proof of cohort %s must have %d steps", cohortId, merkleProofLength(cohort.Count))
+	}
+
+	computed, err := computeMerkleRoot(leafHash, proof)
+	if err != nil {
+		return nil, err
+	}
+
+	revocation, err := getRevokedLeaf(ctx, cohortId, leafHash)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &InclusionResult{
+		CohortId:   cohortId,
+		LeafHash:   leafHash,
+		Included:   bytes.Equal(computed, root),
+		Revoked:    revocation != nil,
+		Revocation: revocation,
+	}
+	result.Valid = result.Included && !result.Revoked
+
+	return result, nil
+}
+
+// RevokeCohortLeaf revoke single certificate (leaf) of anchored cohort.
+// Only the cohort issuer or platform registrar allowed, reasonCode is mandatory.
+func (s *SmartContract) RevokeCohortLeaf(ctx contractapi.TransactionContextInterface,
+	cohortId, leafHash, reasonCode, note string) error {
+
+	if !isValidRevocationReason(reasonCode) {
+		return fmt.Errorf("Invalid revocation reason code: %s", reasonCode)
+	}
+
+	leafHash = strings.ToLower(leafHash)
+
+	cohort, err := s.QueryCohort(ctx, cohortId)
+	if err != nil {
+		return err
+	}
+
+	err = assertIssuerOrRegistrar(ctx, cohort.IssuerId, cohort.IssuerMsp)
+	if err != nil {
+		return err
+	}
+
+	_, err = decodeHash(leafHash)
+	if err != nil {
+		return err
+	}
+
+	revocation, err := getRevokedLeaf(ctx, cohortId, leafHash)
+	if err != nil {
+		return err
+	}
+
+	if revocation != nil {
+		return fmt.Errorf("Leaf %s of cohort %s already revoked", leafHash, cohortId)
+	}
+
+	revokedBy, revokerMsp, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	revocation = &Revocation{
+		ReasonCode: reasonCode,
+		Note:       note,
+		RevokedBy:  revokedBy,
+		RevokerMsp: revokerMsp,
+		RevokedAt:  txTimestamp.Seconds,
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(cohortLeafObjectType, []string{cohortId, leafHash})
+	if err != nil {
+		return err
+	}
+
+	dataBytes, err := json.Marshal(revocation)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, dataBytes)
+	if err != nil {
+		return err
+	}
+
+	entry := newCohortEventEntry(cohortId, cohort)
+	entry.LeafHash = leafHash
+	entry.ReasonCode = reasonCode
+
+	return setCohortEvent(ctx, EventCohortLeafRevoked, entry)
+}
+
+// getRevokedLeaf returns revocation of cohort leaf, or nil if the leaf is not revoked
+func getRevokedLeaf(ctx contractapi.TransactionContextInterface, cohortId, leafHash string) (*Revocation, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(cohortLeafObjectType, []string{cohortId, leafHash})
+	if err != nil {
+		return nil, err
+	}
+
+	dataBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if dataBytes == nil {
+		return nil, nil
+	}
+
+	revocation := new(Revocation)
+	err = json.Unmarshal(dataBytes, revocation)
+	if err != nil {
+		return nil, err
+	}
+
+	return revocation, nil
+}
+
+// computeMerkleRoot folds proof steps from leaf hash up to the root
+func computeMerkleRoot(leafHash string, proof []MerkleProofStep) ([]byte, error) {
+	if len(proof) > maxMerkleProofLength {
+		return nil, fmt.Errorf("Merkle proof exceeds %d steps", maxMerkleProofLength)
+	}
+
+	node, err := decodeHash(leafHash)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, step := range proof {
+		sibling, err := decodeHash(step.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		switch step.Position {
+		case merkleProofLeft:
+			node = hashMerkleNode(sibling, node)
+		case merkleProofRight:
+			node = hashMerkleNode(node, sibling)
+		default:
+			return nil, fmt.Errorf("Invalid merkle proof position: %s", step.Position)
+		}
+	}
+
+	return node, nil
+}
+
+// merkleProofLength returns number of proof steps from leaf to root of tree with count leaves, ceil(log2(count))
+func merkleProofLength(count int64) int {
+	return bits.Len64(uint64(count - 1))
+}
+
+func hashMerkleNode(left, right []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte{merkleNodePrefix})
+	hash.Write(left)
+	hash.Write(right)
+	return hash.Sum(nil)
+}
+
+// decodeHash decodes hex encoded SHA-256 hash
+func decodeHash(hash string) ([]byte, error) {
+	hashBytes, err := hex.DecodeString(hash)
+	if err != nil || len(hashBytes) != sha256.Size {
+		return nil, fmt.Errorf("Invalid SHA-256 hash: %s", hash)
+	}
+
+	return hashBytes, nil
+}
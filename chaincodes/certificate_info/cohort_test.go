@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// hashMerkleLeaf hashes leaf data as issuer does off-chain
+func hashMerkleLeaf(data []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte{0x00})
+	hash.Write(data)
+	return hash.Sum(nil)
+}
+
+// buildMerkleTree returns root and proof of every leaf, pairing last node of odd level with itself
+func buildMerkleTree(leaves [][]byte) ([]byte, [][]MerkleProofStep) {
+	proofs := make([][]MerkleProofStep, len(leaves))
+	positions := make([]int, len(leaves))
+	for i := range positions {
+		positions[i] = i
+	}
+
+	level := leaves
+	for len(level) > 1 {
+		next := [][]byte{}
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, hashMerkleNode(level[i], right))
+		}
+
+		for leaf, position := range positions {
+			if position%2 == 0 {
+				sibling := level[position]
+				if position+1 < len(level) {
+					sibling = level[position+1]
+				}
+				proofs[leaf] = append(proofs[leaf], MerkleProofStep{Hash: hex.EncodeToString(sibling), Position: merkleProofRight})
+			} else {
+				proofs[leaf] = append(proofs[leaf], MerkleProofStep{Hash: hex.EncodeToString(level[position-1]), Position: merkleProofLeft})
+			}
+			positions[leaf] = position / 2
+		}
+
+		level = next
+	}
+
+	return level[0], proofs
+}
+
+func newCohortLeaves(count int) [][]byte {
+	leaves := [][]byte{}
+	for i := 0; i < count; i++ {
+		leaves = append(leaves, hashMerkleLeaf([]byte(fmt.Sprintf("certificate-%d", i))))
+	}
+	return leaves
+}
+
+// newCohortContext returns transaction context with cohort anchored in mock world state
+func newCohortContext(t *testing.T, cohortId string, root []byte, count int64) *contractapi.TransactionContext {
+	t.Helper()
+
+	stub := shimtest.NewMockStub("certificate_info", nil)
+
+	key, err := stub.CreateCompositeKey(cohortObjectType, []string{cohortId})
+	if err != nil {
+		t.Fatalf("Failed to create cohort key. %s", err.Error())
+	}
+
+	dataBytes, err := json.Marshal(&CohortRecord{MerkleRoot: hex.EncodeToString(root), IssuerId: "issuer", Count: count})
+	if err != nil {
+		t.Fatalf("Failed to marshal cohort. %s", err.Error())
+	}
+
+	stub.MockTransactionStart("anchor")
+	err = stub.PutState(key, dataBytes)
+	if err != nil {
+		t.Fatalf("Failed to put cohort. %s", err.Error())
+	}
+	stub.MockTransactionEnd("anchor")
+
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+
+	return ctx
+}
+
+func TestHashMerkleNodePrefix(t *testing.T) {
+	left := sha256.Sum256([]byte("left"))
+	right := sha256.Sum256([]byte("right"))
+
+	expected := sha256.Sum256(append(append([]byte{0x01}, left[:]...), right[:]...))
+	if !bytes.Equal(hashMerkleNode(left[:], right[:]), expected[:]) {
+		t.Errorf("Expected node hash SHA-256(0x01 || left || right)")
+	}
+
+	unprefixed := sha256.Sum256(append(left[:], right[:]...))
+	if bytes.Equal(hashMerkleNode(left[:], right[:]), unprefixed[:]) {
+		t.Errorf("Expected node hash to differ from unprefixed hash")
+	}
+
+	// Leaf and node over the same bytes must never collide
+	data := append(left[:], right[:]...)
+	if bytes.Equal(hashMerkleLeaf(data), hashMerkleNode(left[:], right[:])) {
+		t.Errorf("Expected leaf hash to differ from node hash of the same bytes")
+	}
+}
+
+func TestMerkleProofLength(t *testing.T) {
+	tests := []struct {
+		count    int64
+		expected int
+	}{
+		{1, 0},
+		{2, 1},
+		{3, 2},
+		{4, 2},
+		{5, 3},
+		{8, 3},
+		{9, 4},
+		{1 << 20, 20},
+		{1<<20 + 1, 21},
+	}
+
+	for _, test := range tests {
+		if length := merkleProofLength(test.count); length != test.expected {
+			t.Errorf("Expected proof length %d of %d leaves, got %d", test.expected, test.count, length)
+		}
+	}
+}
+
+func TestComputeMerkleRoot(t *testing.T) {
+	for _, count := range []int{1, 2, 3, 5, 8, 13} {
+		leaves := newCohortLeaves(count)
+		root, proofs := buildMerkleTree(leaves)
+
+		for i, leaf := range leaves {
+			if len(proofs[i]) != merkleProofLength(int64(count)) {
+				t.Errorf("Expected proof of %d steps for %d leaves, got %d", merkleProofLength(int64(count)), count, len(proofs[i]))
+			}
+
+			computed, err := computeMerkleRoot(hex.EncodeToString(leaf), proofs[i])
+			if err != nil {
+				t.Fatalf("Failed to compute root of leaf %d of %d. %s", i, count, err.Error())
+			}
+
+			if !bytes.Equal(computed, root) {
+				t.Errorf("Expected leaf %d of %d to fold into root", i, count)
+			}
+		}
+	}
+}
+
+func TestComputeMerkleRootRejectsInvalidProof(t *testing.T) {
+	leaf := hex.EncodeToString(hashMerkleLeaf([]byte("certificate")))
+	sibling := hex.EncodeToString(hashMerkleLeaf([]byte("sibling")))
+
+	tests := []struct {
+		name  string
+		leaf  string
+		proof []MerkleProofStep
+	}{
+		{"invalid position", leaf, []MerkleProofStep{{Hash: sibling, Position: "up"}}},
+		{"invalid sibling hash", leaf, []MerkleProofStep{{Hash: "00", Position: merkleProofLeft}}},
+		{"invalid leaf hash", "leaf", []MerkleProofStep{{Hash: sibling, Position: merkleProofLeft}}},
+		{"proof too long", leaf, make([]MerkleProofStep, maxMerkleProofLength+1)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := computeMerkleRoot(test.leaf, test.proof)
+			if err == nil {
+				t.Errorf("Expected proof to be rejected")
+			}
+		})
+	}
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	leaves := newCohortLeaves(5)
+	root, proofs := buildMerkleTree(leaves)
+	ctx := newCohortContext(t, "cohort", root, int64(len(leaves)))
+	contract := new(SmartContract)
+
+	for i, leaf := range leaves {
+		result, err := contract.VerifyInclusion(ctx, "cohort", hex.EncodeToString(leaf), proofs[i])
+		if err != nil {
+			t.Fatalf("Failed to verify leaf %d. %s", i, err.Error())
+		}
+
+		if !result.Included || !result.Valid {
+			t.Errorf("Expected leaf %d included and valid", i)
+		}
+	}
+
+	wrongSibling := append([]MerkleProofStep{}, proofs[0]...)
+	wrongSibling[0] = MerkleProofStep{Hash: hex.EncodeToString(hashMerkleLeaf([]byte("other"))), Position: wrongSibling[0].Position}
+
+	result, err := contract.VerifyInclusion(ctx, "cohort", hex.EncodeToString(leaves[0]), wrongSibling)
+	if err != nil {
+		t.Fatalf("Failed to verify leaf with wrong sibling. %s", err.Error())
+	}
+
+	if result.Included || result.Valid {
+		t.Errorf("Expected leaf with wrong sibling not included")
+	}
+}
+
+// Internal node presented as leaf with shorter proof folds into the root, proof length check must reject it
+func TestVerifyInclusionRejectsInternalNodeAsLeaf(t *testing.T) {
+	leaves := newCohortLeaves(4)
+	root, proofs := buildMerkleTree(leaves)
+	ctx := newCohortContext(t, "cohort", root, int64(len(leaves)))
+	contract := new(SmartContract)
+
+	node := hashMerkleNode(leaves[0], leaves[1])
+	shortProof := proofs[0][1:]
+
+	computed, err := computeMerkleRoot(hex.EncodeToString(node), shortProof)
+	if err != nil || !bytes.Equal(computed, root) {
+		t.Fatalf("Expected internal node with shorter proof to fold into root")
+	}
+
+	_, err = contract.VerifyInclusion(ctx, "cohort", hex.EncodeToString(node), shortProof)
+	if err == nil {
+		t.Errorf("Expected internal node with shorter proof to be rejected")
+	}
+
+	longProof := append(append([]MerkleProofStep{}, proofs[0]...), proofs[0][0])
+	_, err = contract.VerifyInclusion(ctx, "cohort", hex.EncodeToString(leaves[0]), longProof)
+	if err == nil {
+		t.Errorf("Expected longer proof to be rejected")
+	}
+}
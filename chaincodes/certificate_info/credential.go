@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	credentialsContext = "https://www.w3.org/2018/credentials/v1"
+	statusListContext  = "https://w3id.org/vc/status-list/2021/v1"
+	// credentialsVocab maps certificate specific terms which are not defined by W3C credentials context
+	credentialsVocab = "urn:cybercert:terms#"
+	// credentialsUrnPrefix is the URN namespace of status identifiers of verifiable credential
+	credentialsUrnPrefix = "urn:cybercert:"
+
+	issuedAtLayout = "2006-01-02 15:04:05"
+)
+
+// VerifiableCredential describes certificate in W3C Verifiable Credentials data model (JSON-LD)
+type VerifiableCredential struct {
+	Context           []interface{}      `json:"@context"`
+	Id                string             `json:"id"`
+	Type              []string           `json:"type"`
+	Issuer            CredentialIssuer   `json:"issuer"`
+	IssuanceDate      string             `json:"issuanceDate"`
+	CredentialSubject CredentialSubject  `json:"credentialSubject"`
+	CredentialStatus  []CredentialStatus `json:"credentialStatus"`
+	Template          CredentialTemplate `json:"template"`
+	Proof             CredentialProof    `json:"proof"`
+}
+
+// CredentialIssuer describes issuer of verifiable credential
+type CredentialIssuer struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// CredentialSubject describes certificate holder and achievement of verifiable credential.
+// Holder name and email are only present when readable by the caller.
+type CredentialSubject struct {
+	Name       string      `json:"name,omitempty"`
+	Email      string      `json:"email,omitempty"`
+	PiiHash    string      `json:"piiHash,omitempty"`
+	CourseName string      `json:"courseName"`
+	ModuleName string      `json:"moduleName"`
+	Extras     interface{} `json:"extras"`
+}
+
+// CredentialStatus describes where verifier can check status of verifiable credential.
+// Certificate with status list index refers StatusList2021 entries of revocation and suspension lists,
+// otherwise carries its current status.
+type CredentialStatus struct {
+	Id                   string `json:"id"`
+	Type                 string `json:"type"`
+	StatusPurpose        string `json:"statusPurpose,omitempty"`
+	StatusListIndex      string `json:"statusListIndex,omitempty"`
+	StatusListCredential string `json:"statusListCredential,omitempty"`
+	Status               string `json:"status,omitempty"`
+}
+
+// CredentialTemplate describes certificate template metadata of verifiable credential
+type CredentialTemplate struct {
+	Id         string `json:"id"`
+	SourceType string `json:"sourceType"`
+	Version    string `json:"version"`
+}
+
+// CredentialProof describes issuer signature of certificate, signed over certificate digest (see GetCertificateDigest)
+type CredentialProof struct {
+	Type               string `json:"type"`
+	ProofPurpose       string `json:"proofPurpose"`
+	VerificationMethod string `json:"verificationMethod"`
+	ProofValue         string `json:"proofValue"`
+}
+
+// GetVerifiableCredential renders certificate and its template metadata as W3C Verifiable Credential.
+// Holder data kept in private data collection is only rendered for members of the issuer organization.
+func (s *SmartContract) GetVerifiableCredential(ctx contractapi.TransactionContextInterface, certKey string) (*VerifiableCredential, error) {
+	certificate, err := s.QueryCertificate(ctx, certKey)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := queryTemplate(ctx, certificate.TemplateRef)
+	if err != nil {
+		return nil, err
+	}
+
+	holder, email, err := readableHolder(ctx, certKey, certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	issuanceDate, err := formatIssuanceDate(certificate.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	credential := &VerifiableCredential{
+		Context: []interface{}{
+			credentialsContext,
+			map[string]string{"@vocab": credentialsVocab},
+		},
+		Id:   urn(certKey),
+		Type: []string{"VerifiableCredential", "CertificateCredential"},
+		Issuer: CredentialIssuer{
+			Id:   urn(certificate.IssuerId),
+			Name: certificate.IssuerName,
+		},
+		IssuanceDate: issuanceDate,
+		CredentialSubject: CredentialSubject{
+			Name:       holder,
+			Email:      email,
+			PiiHash:    certificate.PiiHash,
+			CourseName: certificate.CourseName,
+			ModuleName: certificate.ModuleName,
+			Extras:     certificate.Extras,
+		},
+		CredentialStatus: credentialStatus(ctx, certKey, certificate),
+		Template: CredentialTemplate{
+			Id:         urn(certificate.TemplateRef),
+			SourceType: template.SourceType,
+			Version:    template.Version,
+		},
+		Proof: CredentialProof{
+			Type:               "CertificateSignature",
+			ProofPurpose:       "assertionMethod",
+			VerificationMethod: urn(certificate.IssuerId) + "#" + certificate.SignatureKeyId,
+			ProofValue:         certificate.CertificateSignature,
+		},
+	}
+
+	if certificate.StatusListIndex > 0 {
+		credential.Context = append(credential.Context, statusListContext)
+	}
+
+	return credential, nil
+}
+
+func credentialStatus(ctx contractapi.TransactionContextInterface, certKey string, certificate *CertificateRecord) []CredentialStatus {
+	if certificate.StatusListIndex == 0 {
+		return []CredentialStatus{{
+			Id:     channelUrn(ctx, "certificate", certKey),
+			Type:   "CertificateInfoStatus",
+			Status: certificate.Status,
+		}}
+	}
+
+	index := strconv.FormatInt(certificate.StatusListIndex, 10)
+	entries := []CredentialStatus{}
+
+	for _, statusPurpose := range []string{StatusPurposeRevocation, StatusPurposeSuspension} {
+		listId := statusListId(ctx, certificate.IssuerId, statusPurpose)
+		entries = append(entries, CredentialStatus{
+			Id:                   listId + "#" + index,
+			Type:                 "StatusList2021Entry",
+			StatusPurpose:        statusPurpose,
+			StatusListIndex:      index,
+			StatusListCredential: listId,
+		})
+	}
+
+	return entries
+}
+
+// readableHolder returns certificate holder name and email if readable by the caller
+func readableHolder(ctx contractapi.TransactionContextInterface, certKey string, certificate *CertificateRecord) (string, string, error) {
+	if certificate.PiiCollection == "" || certificate.Erasure != nil {
+		return certificate.CertificateHolder, certificate.Email, nil
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	if mspId != certificate.IssuerMsp {
+		return "", "", nil
+	}
+
+	pii, err := getCertificatePii(ctx, certKey, certificate)
+	if err != nil {
+		return "", "", err
+	}
+
+	return pii.CertificateHolder, pii.Email, nil
+}
+
+func urn(uuid string) string {
+	return "urn:uuid:" + uuid
+}
+
+// channelUrn returns URN of certificate info resource on the channel,
+// example: urn:cybercert:<channel>:certificate_info:status:<issuerId>:revocation
+func channelUrn(ctx contractapi.TransactionContextInterface, parts ...string) string {
+	segments := []string{url.PathEscape(ctx.GetStub().GetChannelID()), "certificate_info"}
+	for _, part := range parts {
+		segments = append(segments, url.PathEscape(part))
+	}
+
+	return credentialsUrnPrefix + strings.Join(segments, ":")
+}
+
+// formatIssuanceDate converts issuedAt (RFC 3339 or "2006-01-02 15:04:05" in UTC) into xsd:dateTime (RFC 3339)
+func formatIssuanceDate(issuedAt string) (string, error) {
+	if _, err := time.Parse(time.RFC3339, issuedAt); err == nil {
+		return issuedAt, nil
+	}
+
+	t, err := time.Parse(issuedAtLayout, issuedAt)
+	if err != nil {
+		return "", fmt.Errorf("Issued at %s must be RFC 3339 date time or in format %s", issuedAt, issuedAtLayout)
+	}
+
+	return t.UTC().Format(time.RFC3339), nil
+}
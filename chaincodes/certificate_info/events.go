@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventSchemaVersion is the version of CertificateEvent payload. It is incremented on incompatible change,
+// new optional fields are added without changing the version.
+const EventSchemaVersion = 1
+
+// Chaincode event names emitted on certificate lifecycle change
+const (
+	EventCertificateIssued       = "CertificateIssued"
+	EventCertificateReissued     = "CertificateReissued"
+	EventCertificateRevoked      = "CertificateRevoked"
+	EventCertificateSuspended    = "CertificateSuspended"
+	EventCertificateReinstated   = "CertificateReinstated"
+	EventCertificateExpired      = "CertificateExpired"
+	EventCertificateHolderErased = "CertificateHolderDataErased"
+	EventCohortAnchored          = "CohortAnchored"
+	EventCohortLeafRevoked       = "CohortLeafRevoked"
+)
+
+// schemaVersion: version of event payload (EventSchemaVersion)
+// eventName: chaincode event name
+// txId: transaction id emitting the event
+// timestamp: transaction timestamp (unix seconds)
+// certificates: certificates changed by the transaction (Fabric keeps one event per transaction)
+// cohort: cohort changed by the transaction, only present in cohort events
+
+// CertificateEvent describes payload of certificate lifecycle chaincode event. It never contains holder data.
+type CertificateEvent struct {
+	SchemaVersion int                     `json:"schema_version"`
+	EventName     string                  `json:"event_name"`
+	TxId          string                  `json:"tx_id"`
+	Timestamp     int64                   `json:"timestamp"`
+	Certificates  []CertificateEventEntry `json:"certificates"`
+	Cohort        *CohortEventEntry       `json:"cohort,omitempty"`
+}
+
+// CertificateEventEntry describes state of certificate after the change
+type CertificateEventEntry struct {
+	CertificateKey  string `json:"certificate_key"`
+	IssuerId        string `json:"issuer_id"`
+	Status          string `json:"status"`
+	StatusListIndex int64  `json:"status_list_index"`
+	ReasonCode      string `json:"reason_code,omitempty"`
+	Supersedes      string `json:"supersedes,omitempty"`
+	SupersededBy    string `json:"superseded_by,omitempty"`
+}
+
+// CohortEventEntry describes anchored cohort, or revoked leaf of the cohort
+type CohortEventEntry struct {
+	CohortId   string `json:"cohort_id"`
+	IssuerId   string `json:"issuer_id"`
+	MerkleRoot string `json:"merkle_root"`
+	Count      int64  `json:"count"`
+	LeafHash   string `json:"leaf_hash,omitempty"`
+	ReasonCode string `json:"reason_code,omitempty"`
+}
+
+func newEventEntry(certKey string, certificate *CertificateRecord) CertificateEventEntry {
+	entry := CertificateEventEntry{
+		CertificateKey:  certKey,
+		IssuerId:        certificate.IssuerId,
+		Status:          certificate.Status,
+		StatusListIndex: certificate.StatusListIndex,
+		Supersedes:      certificate.Supersedes,
+		SupersededBy:    certificate.SupersededBy,
+	}
+
+	if certificate.Revocation != nil {
+		entry.ReasonCode = certificate.Revocation.ReasonCode
+	}
+
+	return entry
+}
+
+func newCohortEventEntry(cohortId string, cohort *CohortRecord) *CohortEventEntry {
+	return &CohortEventEntry{
+		CohortId:   cohortId,
+		IssuerId:   cohort.IssuerId,
+		MerkleRoot: cohort.MerkleRoot,
+		Count:      cohort.Count,
+	}
+}
+
+// setCertificateEvent set chaincode event of the transaction, replacing event set before
+func setCertificateEvent(ctx contractapi.TransactionContextInterface, eventName string, entries ...CertificateEventEntry) error {
+	return setEvent(ctx, eventName, entries, nil)
+}
+
+// setCohortEvent set chaincode event of cohort change, replacing event set before
+func setCohortEvent(ctx contractapi.TransactionContextInterface, eventName string, cohort *CohortEventEntry) error {
+	return setEvent(ctx, eventName, []CertificateEventEntry{}, cohort)
+}
+
+func setEvent(ctx contractapi.TransactionContextInterface, eventName string,
+	entries []CertificateEventEntry, cohort *CohortEventEntry) error {
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	event := CertificateEvent{
+		SchemaVersion: EventSchemaVersion,
+		EventName:     eventName,
+		TxId:          ctx.GetStub().GetTxID(),
+		Timestamp:     txTimestamp.Seconds,
+		Certificates:  entries,
+		Cohort:        cohort,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(eventName, payload)
+}
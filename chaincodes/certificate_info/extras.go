@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// validateExtras validates certificate extras against JSON Schema of template.
+// Template without schema accepts any extras.
+func validateExtras(templateRef string, template *TemplateRecord, extras interface{}) error {
+	if template.ExtrasSchema == nil {
+		return nil
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(template.ExtrasSchema))
+	if err != nil {
+		return fmt.Errorf("Invalid extras schema of template %s. %s", templateRef, err.Error())
+	}
+
+	result, err := schema.Validate(gojsonschema.NewGoLoader(extras))
+	if err != nil {
+		return fmt.Errorf("Failed to validate extras. %s", err.Error())
+	}
+
+	if result.Valid() {
+		return nil
+	}
+
+	fieldErrors := []string{}
+	for _, fieldError := range result.Errors() {
+		fieldErrors = append(fieldErrors, fmt.Sprintf("%s: %s", fieldError.Field(), fieldError.Description()))
+	}
+
+	return fmt.Errorf("Extras do not match schema of template %s. %s", templateRef, strings.Join(fieldErrors, "; "))
+}
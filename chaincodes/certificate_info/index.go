@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Secondary indexes of certificates maintained as composite keys, so lookups work on both LevelDB and CouchDB.
+// Holder index is kept in private data collection of issuer organization to not expose holder email.
+const (
+	holderIndex = "holder~certKey"
+	issuerIndex = "issuer~certKey"
+	courseIndex = "course~certKey"
+)
+
+// indexValue is stored as composite key value, empty value would delete the key
+var indexValue = []byte{0x00}
+
+// GetCertificatesByHolder returns certificates of holder email issued by the caller organization
+func (s *SmartContract) GetCertificatesByHolder(ctx contractapi.TransactionContextInterface, email string) ([]*QueryResult, error) {
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(piiCollection(mspId), holderIndex, []string{normalizeEmail(email)})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return s.constructQueryResponseFromIndex(ctx, resultsIterator)
+}
+
+// GetCertificatesByIssuer returns certificates issued by issuerId
+func (s *SmartContract) GetCertificatesByIssuer(ctx contractapi.TransactionContextInterface, issuerId string) ([]*QueryResult, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(issuerIndex, []string{issuerId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return s.constructQueryResponseFromIndex(ctx, resultsIterator)
+}
+
+// GetCertificatesByCourse returns certificates of courseName
+func (s *SmartContract) GetCertificatesByCourse(ctx contractapi.TransactionContextInterface, courseName string) ([]*QueryResult, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(courseIndex, []string{courseName})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return s.constructQueryResponseFromIndex(ctx, resultsIterator)
+}
+
+// constructQueryResponseFromIndex read certificates referenced by index entries, certKey is the last attribute
+func (s *SmartContract) constructQueryResponseFromIndex(ctx contractapi.TransactionContextInterface,
+	resultsIterator shim.StateQueryIteratorInterface) ([]*QueryResult, error) {
+
+	results := []*QueryResult{}
+
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		certKey := attributes[len(attributes)-1]
+		certificate, err := s.QueryCertificate(ctx, certKey)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &QueryResult{Key: certKey, Record: certificate})
+	}
+
+	return results, nil
+}
+
+// putCertificateIndexes write secondary index entries of newly issued certificate
+func putCertificateIndexes(ctx contractapi.TransactionContextInterface, certKey string, certificate *CertificateRecord, pii *CertificatePii) error {
+	key, err := ctx.GetStub().CreateCompositeKey(issuerIndex, []string{certificate.IssuerId, certKey})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, indexValue)
+	if err != nil {
+		return err
+	}
+
+	key, err = ctx.GetStub().CreateCompositeKey(courseIndex, []string{certificate.CourseName, certKey})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, indexValue)
+	if err != nil {
+		return err
+	}
+
+	if pii.Email == "" {
+		return nil
+	}
+
+	key, err = ctx.GetStub().CreateCompositeKey(holderIndex, []string{normalizeEmail(pii.Email), certKey})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutPrivateData(certificate.PiiCollection, key, indexValue)
+}
+
+// deleteHolderIndex remove holder index entry of certificate
+func deleteHolderIndex(ctx contractapi.TransactionContextInterface, certKey string, certificate *CertificateRecord, pii *CertificatePii) error {
+	if pii.Email == "" {
+		return nil
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(holderIndex, []string{normalizeEmail(pii.Email), certKey})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelPrivateData(certificate.PiiCollection, key)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// BackfillResult describes progress of index backfill
+type BackfillResult struct {
+	Indexed   int    `json:"indexed"`
+	NextKey   string `json:"next_key"`
+	Completed bool   `json:"completed"`
+}
+
+// BackfillIndexes write issuer and course index entries of certificates issued before the indexes were introduced,
+// at most limit certificates per transaction starting from startKey (empty for the first call). If result is not completed,
+// call again with returned next key. Entries are rewritten as is, so running it over indexed certificates is harmless.
+// Holder index is not backfilled, legacy holder data is not kept in private data collection. Only platform registrar allowed.
+func (s *SmartContract) BackfillIndexes(ctx contractapi.TransactionContextInterface, startKey string, limit int) (*BackfillResult, error) {
+	err := assertRegistrar(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > MaxPageSize {
+		return nil, fmt.Errorf("Limit must be between 1 and %d", MaxPageSize)
+	}
+
+	// Range query skips composite keys (status list, cohort, config and index entries)
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	result := &BackfillResult{}
+
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Indexed == limit {
+			result.NextKey = queryResult.Key
+			return result, nil
+		}
+
+		certificate := new(CertificateRecord)
+		err = json.Unmarshal(queryResult.Value, certificate)
+		if err != nil {
+			return nil, err
+		}
+
+		err = putCertificateIndexes(ctx, queryResult.Key, certificate, &CertificatePii{})
+		if err != nil {
+			return nil, err
+		}
+
+		result.Indexed++
+	}
+
+	result.Completed = true
+
+	return result, nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// TransientPiiKey is the transient map key of certificate holder PII for IssueCertificate
+	TransientPiiKey = "certificate_pii"
+	// TransientBatchPiiKey is the transient map key of certificate holder PII keyed by certificate key for IssueCertificatesBatch
+	TransientBatchPiiKey = "certificates_pii"
+
+	// MinPiiSaltLength is minimum length of salt used for hashing PII
+	MinPiiSaltLength = 16
+)
+
+// certificateHolder: name of certificate holder
+// email: email of certificate holder
+// salt: random salt chosen by issuer, prevents guessing PII from its hash
+
+// CertificatePii describes certificate holder data kept in private data collection of issuer organization
+type CertificatePii struct {
+	CertificateHolder string `json:"certificate_holder"`
+	Email             string `json:"email"`
+	Salt              string `json:"salt"`
+}
+
+// QueryCertificatePii returns certificate holder data. Only members of the issuer organization allowed.
+func (s *SmartContract) QueryCertificatePii(ctx contractapi.TransactionContextInterface, certKey string) (*CertificatePii, error) {
+	certificate, err := s.QueryCertificate(ctx, certKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if certificate.Erasure != nil {
+		return nil, fmt.Errorf("Certificate %s holder data erased", certKey)
+	}
+
+	if certificate.PiiCollection == "" {
+		return nil, fmt.Errorf("Certificate %s holder data is not stored in private data collection", certKey)
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	if mspId != certificate.IssuerMsp {
+		return nil, fmt.Errorf("Client identity of %s is not member of collection %s", mspId, certificate.PiiCollection)
+	}
+
+	return getCertificatePii(ctx, certKey, certificate)
+}
+
+// EraseHolderData erase certificate holder data on holder request (right to erasure) and leave erasure tombstone.
+// Only the original issuer or platform registrar allowed. Certificate stays verifiable through salted hash of holder data.
+func (s *SmartContract) EraseHolderData(ctx contractapi.TransactionContextInterface, certKey string) error {
+	certificate, err := s.QueryCertificate(ctx, certKey)
+	if err != nil {
+		return err
+	}
+
+	if certificate.Erasure != nil {
+		return fmt.Errorf("Certificate %s holder data already erased", certKey)
+	}
+
+	err = assertIssuerOrRegistrar(ctx, certificate.IssuerId, certificate.IssuerMsp)
+	if err != nil {
+		return err
+	}
+
+	erasedBy, erasureMsp, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	if certificate.PiiCollection != "" {
+		pii, err := getCertificatePii(ctx, certKey, certificate)
+		if err != nil {
+			return err
+		}
+
+		err = deleteHolderIndex(ctx, certKey, certificate, pii)
+		if err != nil {
+			return err
+		}
+
+		// DelPrivateData keeps the value in private data history of peers.
+		// Switch to PurgePrivateData once peers run Fabric 2.5 to remove it completely.
+		err = ctx.GetStub().DelPrivateData(certificate.PiiCollection, certKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Certificates issued before holder data moved into private data keep it in public record.
+	// Previous versions remain in ledger history.
+	certificate.CertificateHolder = ""
+	certificate.Email = ""
+	certificate.Erasure = &Erasure{
+		ErasedBy:   erasedBy,
+		ErasureMsp: erasureMsp,
+		ErasedAt:   txTimestamp.Seconds,
+	}
+
+	err = putCertificate(ctx, certKey, certificate)
+	if err != nil {
+		return err
+	}
+
+	return setCertificateEvent(ctx, EventCertificateHolderErased, newEventEntry(certKey, certificate))
+}
+
+// getCertificatePii read certificate holder data from private data collection
+func getCertificatePii(ctx contractapi.TransactionContextInterface, certKey string, certificate *CertificateRecord) (*CertificatePii, error) {
+	dataBytes, err := ctx.GetStub().GetPrivateData(certificate.PiiCollection, certKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from private data collection %s. %s", certificate.PiiCollection, err.Error())
+	}
+
+	if dataBytes == nil {
+		return nil, fmt.Errorf("Certificate %s holder data does not exist", certKey)
+	}
+
+	pii := new(CertificatePii)
+	err = json.Unmarshal(dataBytes, pii)
+	if err != nil {
+		return nil, err
+	}
+
+	return pii, nil
+}
+
+// putCertificatePii write certificate holder data into private data collection of issuer organization
+func putCertificatePii(ctx contractapi.TransactionContextInterface, certKey string, certificate *CertificateRecord, pii *CertificatePii) error {
+	dataBytes, err := json.Marshal(pii)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutPrivateData(certificate.PiiCollection, certKey, dataBytes)
+}
+
+// getTransientPii read certificate holder data of IssueCertificate from transient map
+func getTransientPii(ctx contractapi.TransactionContextInterface) (*CertificatePii, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read transient map. %s", err.Error())
+	}
+
+	dataBytes, ok := transientMap[TransientPiiKey]
+	if !ok {
+		return nil, fmt.Errorf("Certificate holder data must be passed in transient map key %s", TransientPiiKey)
+	}
+
+	pii := new(CertificatePii)
+	err = json.Unmarshal(dataBytes, pii)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid certificate holder data. %s", err.Error())
+	}
+
+	return pii, nil
+}
+
+// getTransientBatchPii read certificate holder data of IssueCertificatesBatch from transient map
+func getTransientBatchPii(ctx contractapi.TransactionContextInterface) (map[string]*CertificatePii, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read transient map. %s", err.Error())
+	}
+
+	dataBytes, ok := transientMap[TransientBatchPiiKey]
+	if !ok {
+		return nil, fmt.Errorf("Certificate holder data must be passed in transient map key %s", TransientBatchPiiKey)
+	}
+
+	pii := map[string]*CertificatePii{}
+	err = json.Unmarshal(dataBytes, &pii)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid certificate holder data. %s", err.Error())
+	}
+
+	return pii, nil
+}
+
+// hashPii returns hex encoded SHA-256 digest of canonical JSON (RFC 8785) of certificate holder data
+func hashPii(pii *CertificatePii) (string, error) {
+	if pii == nil {
+		return "", fmt.Errorf("Certificate holder data must not be empty")
+	}
+
+	if pii.CertificateHolder == "" {
+		return "", fmt.Errorf("Certificate holder must not be empty")
+	}
+
+	if len(pii.Salt) < MinPiiSaltLength {
+		return "", fmt.Errorf("Salt must be at least %d characters", MinPiiSaltLength)
+	}
+
+	canonical, err := canonicalJSON(pii)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(canonical)
+
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// piiCollection returns implicit private data collection of organization
+func piiCollection(mspId string) string {
+	return "_implicit_org_" + mspId
+}
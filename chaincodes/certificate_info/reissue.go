@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxSupersedeChainLength bounds number of re-issuances followed by GetCurrentCertificate
+const maxSupersedeChainLength = 100
+
+// ReissueCertificate issue new certificate newKey replacing certificate oldKey (example: misspelled holder name, changed grade)
+// and mark oldKey as superseded in the same transaction. Only the original issuer allowed.
+// New certificate holder data is passed in transient map as in IssueCertificate, issuer signature covers supersedes pointer.
+func (s *SmartContract) ReissueCertificate(ctx contractapi.TransactionContextInterface,
+	oldKey, newKey, certSignature, templateRef, courseName, moduleName, issuedAt string, extras interface{}) error {
+
+	pii, err := getTransientPii(ctx)
+	if err != nil {
+		return err
+	}
+
+	previous, err := s.prepareStatusChange(ctx, oldKey, StatusSuperseded)
+	if err != nil {
+		return err
+	}
+
+	payload := &CertificatePayload{
+		CertificateKey:       newKey,
+		CertificateSignature: certSignature,
+		TemplateRef:          templateRef,
+		CourseName:           courseName,
+		ModuleName:           moduleName,
+		IssuerId:             previous.IssuerId,
+		IssuedAt:             issuedAt,
+		Extras:               extras,
+		Supersedes:           oldKey,
+	}
+
+	certificate, err := s.prepareCertificate(ctx, payload, pii, newIssuance())
+	if err != nil {
+		return err
+	}
+
+	err = putCertificate(ctx, newKey, certificate)
+	if err != nil {
+		return err
+	}
+
+	err = putCertificatePii(ctx, newKey, certificate, pii)
+	if err != nil {
+		return err
+	}
+
+	err = putCertificateIndexes(ctx, newKey, certificate, pii)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	previous.Status = StatusSuperseded
+	previous.Suspension = nil
+	previous.SupersededBy = newKey
+	previous.SupersededAt = txTimestamp.Seconds
+
+	err = putCertificateStatus(ctx, oldKey, previous)
+	if err != nil {
+		return err
+	}
+
+	return setCertificateEvent(ctx, EventCertificateReissued, newEventEntry(oldKey, previous), newEventEntry(newKey, certificate))
+}
+
+// GetCurrentCertificate follows supersede chain from certKey and returns the latest version of certificate.
+// Certificate which is not superseded is returned as is, its status tells whether it is still valid.
+func (s *SmartContract) GetCurrentCertificate(ctx contractapi.TransactionContextInterface, certKey string) (*QueryResult, error) {
+	certificate, err := s.QueryCertificate(ctx, certKey)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; certificate.SupersededBy != ""; i++ {
+		if i == maxSupersedeChainLength {
+			return nil, fmt.Errorf("Certificate %s supersede chain exceeds %d re-issuances", certKey, maxSupersedeChainLength)
+		}
+
+		certKey = certificate.SupersededBy
+		certificate, err = s.QueryCertificate(ctx, certKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &QueryResult{Key: certKey, Record: certificate}, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+)
+
+// Signature algorithms of issuer public key registered in issuer registry
+// - ECDSA_P256: ASN.1 DER encoded ECDSA signature over SHA-256 digest of canonical certificate (certificate digest).
+// - ED25519: Ed25519 signature over canonical certificate.
+const (
+	KeyAlgorithmECDSAP256 = "ECDSA_P256"
+	KeyAlgorithmEd25519   = "ED25519"
+)
+
+// signedCertificate lists certificate fields covered by issuer signature.
+// Certificate holder data is covered through its salted hash, certificates issued before
+// holder data moved into private data cover holder and email instead. Re-issued certificate also covers key of
+// certificate it supersedes.
+type signedCertificate struct {
+	CertificateKey    string      `json:"certificate_key"`
+	TemplateRef       string      `json:"template_ref"`
+	CourseName        string      `json:"course_name"`
+	ModuleName        string      `json:"module_name"`
+	CertificateHolder string      `json:"certificate_holder,omitempty"`
+	Email             string      `json:"email,omitempty"`
+	PiiHash           string      `json:"pii_hash,omitempty"`
+	IssuerId          string      `json:"issuer_id"`
+	IssuedAt          string      `json:"issued_at"`
+	Extras            interface{} `json:"extras"`
+	Supersedes        string      `json:"supersedes,omitempty"`
+}
+
+// canonicalCertificate returns canonical JSON (RFC 8785) of certificate fields signed by issuer
+func canonicalCertificate(certKey string, certificate *CertificateRecord) ([]byte, error) {
+	return canonicalJSON(signedCertificate{
+		CertificateKey:    certKey,
+		TemplateRef:       certificate.TemplateRef,
+		CourseName:        certificate.CourseName,
+		ModuleName:        certificate.ModuleName,
+		CertificateHolder: certificate.CertificateHolder,
+		Email:             certificate.Email,
+		PiiHash:           certificate.PiiHash,
+		IssuerId:          certificate.IssuerId,
+		IssuedAt:          certificate.IssuedAt,
+		Extras:            certificate.Extras,
+		Supersedes:        certificate.Supersedes,
+	})
+}
+
+// verifyIssuerSignature verifies certificate signature against non-revoked public keys of issuer
+// and returns key id of matching key
+func verifyIssuerSignature(issuer *IssuerRecord, signature string, message []byte) (string, error) {
+	for _, key := range issuer.PublicKeys {
+		if key.IsRevoked {
+			continue
+		}
+
+		if verifySignature(key.Algorithm, key.PublicKey, signature, message) == nil {
+			return key.KeyId, nil
+		}
+	}
+
+	return "", fmt.Errorf("Certificate signature does not match any public key of issuer")
+}
+
+// verifySignature verifies base64 encoded signature of message against PEM encoded public key
+func verifySignature(algorithm, publicKeyPem, signature string, message []byte) error {
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("Signature is not base64 encoded. %s", err.Error())
+	}
+
+	block, _ := pem.Decode([]byte(publicKeyPem))
+	if block == nil {
+		return fmt.Errorf("Public key is not PEM encoded")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("Failed to parse public key. %s", err.Error())
+	}
+
+	switch algorithm {
+	case KeyAlgorithmECDSAP256:
+		ecdsaKey, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok || ecdsaKey.Curve != elliptic.P256() {
+			return fmt.Errorf("Public key is not ECDSA P-256 key")
+		}
+
+		digest := sha256.Sum256(message)
+		if !ecdsa.VerifyASN1(ecdsaKey, digest[:], signatureBytes) {
+			return fmt.Errorf("Invalid ECDSA signature")
+		}
+
+	case KeyAlgorithmEd25519:
+		ed25519Key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("Public key is not Ed25519 key")
+		}
+
+		if !ed25519.Verify(ed25519Key, message, signatureBytes) {
+			return fmt.Errorf("Invalid Ed25519 signature")
+		}
+
+	default:
+		return fmt.Errorf("Unsupported key algorithm: %s", algorithm)
+	}
+
+	return nil
+}
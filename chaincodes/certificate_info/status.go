@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Certificate status
+// - Active: certificate valid.
+// - Suspended: certificate temporarily invalid (example: during disciplinary investigation), can be reinstated.
+// - Revoked: certificate permanently invalid.
+// - Superseded: certificate replaced by re-issued certificate.
+// - Expired: certificate no longer valid due to expiration.
+const (
+	StatusActive     = "ACTIVE"
+	StatusSuspended  = "SUSPENDED"
+	StatusRevoked    = "REVOKED"
+	StatusSuperseded = "SUPERSEDED"
+	StatusExpired    = "EXPIRED"
+)
+
+// statusTransitions lists allowed transitions from each status.
+// Revoked, superseded and expired are final.
+var statusTransitions = map[string][]string{
+	StatusActive:    {StatusSuspended, StatusRevoked, StatusSuperseded, StatusExpired},
+	StatusSuspended: {StatusActive, StatusRevoked, StatusExpired},
+}
+
+// assertStatusTransition returns error if certificate is not allowed to change status from current to next
+func assertStatusTransition(certKey, current, next string) error {
+	for _, allowed := range statusTransitions[current] {
+		if allowed == next {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Certificate %s cannot change status from %s to %s", certKey, current, next)
+}
+
+// UnmarshalJSON decodes certificate record. Records written before status introduced
+// only carry is_revoked flag, their status is derived from the flag.
+func (c *CertificateRecord) UnmarshalJSON(data []byte) error {
+	type certificateRecord CertificateRecord
+	record := struct {
+		*certificateRecord
+		IsRevoked bool `json:"is_revoked"`
+	}{
+		certificateRecord: (*certificateRecord)(c),
+	}
+
+	err := json.Unmarshal(data, &record)
+	if err != nil {
+		return err
+	}
+
+	if c.Status == "" {
+		c.Status = StatusActive
+		if record.IsRevoked {
+			c.Status = StatusRevoked
+		}
+	}
+
+	return nil
+}
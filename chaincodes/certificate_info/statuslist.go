@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Status purposes of status list
+// - revocation: bit set once certificate revoked, superseded or expired. Final.
+// - suspension: bit set while certificate suspended.
+const (
+	StatusPurposeRevocation = "revocation"
+	StatusPurposeSuspension = "suspension"
+
+	// MinStatusListLength is minimum bitstring length (16KB) for holder privacy
+	MinStatusListLength = 131072
+
+	statusListObjectType = "status_list"
+	statusBitObjectType  = "status_bit"
+)
+
+// StatusListCounter stores next status list index of issuer
+type StatusListCounter struct {
+	NextIndex int64 `json:"next_index"`
+}
+
+// StatusList describes StatusList2021 bitstring of issuer. Bit at certificate status_list_index is set
+// when the status applies. Encoded list is GZIP compressed, base64url encoded (without padding) bitstring,
+// the first index is the most significant bit of the first byte.
+type StatusList struct {
+	Id            string `json:"id"`
+	IssuerId      string `json:"issuer_id"`
+	StatusPurpose string `json:"status_purpose"`
+	Length        int64  `json:"length"`
+	EncodedList   string `json:"encoded_list"`
+}
+
+// GetStatusList returns encoded status list of issuer for the given purpose (revocation or suspension)
+func (s *SmartContract) GetStatusList(ctx contractapi.TransactionContextInterface, issuerId, statusPurpose string) (*StatusList, error) {
+	if statusPurpose != StatusPurposeRevocation && statusPurpose != StatusPurposeSuspension {
+		return nil, fmt.Errorf("Invalid status purpose: %s", statusPurpose)
+	}
+
+	nextIndex, err := getNextStatusListIndex(ctx, issuerId)
+	if err != nil {
+		return nil, err
+	}
+
+	length := int64(MinStatusListLength)
+	if nextIndex > length {
+		length = (nextIndex + 7) / 8 * 8
+	}
+	bitstring := make([]byte, length/8)
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(statusBitObjectType, []string{issuerId, statusPurpose})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err := strconv.ParseInt(attributes[2], 10, 64)
+		if err != nil || index < 0 || index >= length {
+			return nil, fmt.Errorf("Invalid status list index: %s", attributes[2])
+		}
+
+		bitstring[index/8] |= 0x80 >> uint(index%8)
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err = writer.Write(bitstring)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatusList{
+		Id:            statusListId(ctx, issuerId, statusPurpose),
+		IssuerId:      issuerId,
+		StatusPurpose: statusPurpose,
+		Length:        length,
+		EncodedList:   base64.RawURLEncoding.EncodeToString(compressed.Bytes()),
+	}, nil
+}
+
+// allocateStatusListIndex assign next status list index of issuer. Index zero is reserved for
+// certificates issued before status list introduced.
+func (tx *issuance) allocateStatusListIndex(ctx contractapi.TransactionContextInterface, issuerId string) (int64, error) {
+	nextIndex, ok := tx.nextIndex[issuerId]
+	if !ok {
+		var err error
+		nextIndex, err = getNextStatusListIndex(ctx, issuerId)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	tx.nextIndex[issuerId] = nextIndex + 1
+
+	key, err := ctx.GetStub().CreateCompositeKey(statusListObjectType, []string{issuerId})
+	if err != nil {
+		return 0, err
+	}
+
+	dataBytes, err := json.Marshal(StatusListCounter{NextIndex: nextIndex + 1})
+	if err != nil {
+		return 0, err
+	}
+
+	err = ctx.GetStub().PutState(key, dataBytes)
+	if err != nil {
+		return 0, err
+	}
+
+	return nextIndex, nil
+}
+
+func getNextStatusListIndex(ctx contractapi.TransactionContextInterface, issuerId string) (int64, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(statusListObjectType, []string{issuerId})
+	if err != nil {
+		return 0, err
+	}
+
+	dataBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if dataBytes == nil {
+		return 1, nil
+	}
+
+	counter := new(StatusListCounter)
+	err = json.Unmarshal(dataBytes, counter)
+	if err != nil {
+		return 0, err
+	}
+
+	return counter.NextIndex, nil
+}
+
+// putCertificateStatus write certificate and reflect its status into status lists of issuer
+func putCertificateStatus(ctx contractapi.TransactionContextInterface, certKey string, certificate *CertificateRecord) error {
+	if certificate.StatusListIndex > 0 {
+		err := setStatusBit(ctx, certificate, StatusPurposeSuspension, certificate.Status == StatusSuspended)
+		if err != nil {
+			return err
+		}
+
+		final := certificate.Status == StatusRevoked || certificate.Status == StatusSuperseded || certificate.Status == StatusExpired
+		if final {
+			err = setStatusBit(ctx, certificate, StatusPurposeRevocation, true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return putCertificate(ctx, certKey, certificate)
+}
+
+// setStatusBit set or clear bit of certificate in status list of issuer
+func setStatusBit(ctx contractapi.TransactionContextInterface, certificate *CertificateRecord, statusPurpose string, value bool) error {
+	index := strconv.FormatInt(certificate.StatusListIndex, 10)
+	key, err := ctx.GetStub().CreateCompositeKey(statusBitObjectType, []string{certificate.IssuerId, statusPurpose, index})
+	if err != nil {
+		return err
+	}
+
+	if !value {
+		return ctx.GetStub().DelState(key)
+	}
+
+	return ctx.GetStub().PutState(key, []byte{0x01})
+}
+
+// statusListId returns identifier of issuer status list, referenced by verifiable credential
+func statusListId(ctx contractapi.TransactionContextInterface, issuerId, statusPurpose string) string {
+	return channelUrn(ctx, "status", issuerId, statusPurpose)
+}
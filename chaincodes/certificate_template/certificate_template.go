@@ -3,21 +3,36 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 type SmartContract struct {
 	contractapi.Contract
 }
 
+const (
+	// IssuerIdAttribute is the X.509 attribute (registered through Fabric CA) binding an enrolled identity to issuer id
+	IssuerIdAttribute = "issuer_id"
+	// IssuerRegistryChaincode is the chaincode name of issuer registry on the same channel
+	IssuerRegistryChaincode = "issuer_registry"
+
+	IssuerActive = "ACTIVE"
+)
+
 // templateKey: template key (uuid) associated with templateRef in certificate info
 // templateSource: source code of template
 // sourceType: format type of source (example: json)
 // version: version of template
 // issuerId: issuer id or reference on blockchain
 // issuerName: name of academic institution
+// extrasSchema: optional JSON Schema validating extras of certificates issued from template
+// deprecated: template no longer used for new certificates, certificates already issued stay valid
+// deprecatedAt: transaction timestamp of deprecation (unix seconds)
 
 // TemplateRecord store template source code of issued certificate
 type CertificateTemplate struct {
@@ -26,6 +41,17 @@ type CertificateTemplate struct {
 	Version        string      `json:"version"`
 	IssuerId       string      `json:"issuer_id"`
 	IssuerName     string      `json:"issuer_name"`
+	ExtrasSchema   interface{} `json:"extras_schema,omitempty"`
+	Deprecated     bool        `json:"deprecated"`
+	DeprecatedAt   int64       `json:"deprecated_at,omitempty"`
+}
+
+// IssuerRecord describes issuer registered in issuer registry chaincode
+type IssuerRecord struct {
+	MspId               string `json:"msp_id"`
+	IssuerName          string `json:"issuer_name"`
+	AccreditationStatus string `json:"accreditation_status"`
+	Status              string `json:"status"`
 }
 
 // QueryResult structure used for handling result of query
@@ -48,6 +74,34 @@ func (s *SmartContract) PutTemplate(
 	templateSource interface{},
 	sourceType, version, issuerId, issuerName string) error {
 
+	return s.PutTemplateWithSchema(ctx, templateKey, templateSource, sourceType, version, issuerId, issuerName, nil)
+}
+
+// PutTemplateWithSchema store template with JSON Schema which extras of certificates issued from the template must match.
+// Schema must not reference external documents.
+func (s *SmartContract) PutTemplateWithSchema(
+	ctx contractapi.TransactionContextInterface,
+	templateKey string,
+	templateSource interface{},
+	sourceType, version, issuerId, issuerName string,
+	extrasSchema interface{}) error {
+
+	if extrasSchema != nil {
+		err := validateSchema(extrasSchema)
+		if err != nil {
+			return err
+		}
+	}
+
+	issuer, err := assertIssuerIdentity(ctx, issuerId)
+	if err != nil {
+		return err
+	}
+
+	if issuerName != "" && issuerName != issuer.IssuerName {
+		return fmt.Errorf("Issuer name %s does not match registered name of issuer %s", issuerName, issuerId)
+	}
+
 	cert, _ := s.QueryTemplate(ctx, templateKey)
 	if cert != nil {
 		return fmt.Errorf("Template %s already issued", templateKey)
@@ -58,15 +112,39 @@ func (s *SmartContract) PutTemplate(
 		SourceType:     sourceType,
 		Version:        version,
 		IssuerId:       issuerId,
-		IssuerName:     issuerName,
+		IssuerName:     issuer.IssuerName,
+		ExtrasSchema:   extrasSchema,
 	}
 
-	dataBytes, err := json.Marshal(template)
+	return putTemplate(ctx, templateKey, &template)
+}
+
+// DeprecateTemplate mark template as deprecated so no new certificate can be issued from it.
+// Only the template issuer allowed.
+func (s *SmartContract) DeprecateTemplate(ctx contractapi.TransactionContextInterface, templateKey string) error {
+	template, err := s.QueryTemplate(ctx, templateKey)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(templateKey, dataBytes)
+	_, err = assertIssuerIdentity(ctx, template.IssuerId)
+	if err != nil {
+		return err
+	}
+
+	if template.Deprecated {
+		return fmt.Errorf("Template %s already deprecated", templateKey)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	template.Deprecated = true
+	template.DeprecatedAt = txTimestamp.Seconds
+
+	return putTemplate(ctx, templateKey, template)
 }
 
 func (s *SmartContract) QueryTemplate(ctx contractapi.TransactionContextInterface, certKey string) (*CertificateTemplate, error) {
@@ -125,6 +203,109 @@ func (s *SmartContract) GetHistoryForKey(ctx contractapi.TransactionContextInter
 	return results, nil
 }
 
+// validateSchema verifies extras schema compiles and only uses references within the schema document
+// (external references would be fetched by each endorsing peer)
+func validateSchema(schema interface{}) error {
+	err := assertLocalReferences(schema)
+	if err != nil {
+		return err
+	}
+
+	_, err = gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+	if err != nil {
+		return fmt.Errorf("Invalid extras schema. %s", err.Error())
+	}
+
+	return nil
+}
+
+func assertLocalReferences(node interface{}) error {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := value["$ref"].(string); ok && !strings.HasPrefix(ref, "#") {
+			return fmt.Errorf("Extras schema must not reference external document %s", ref)
+		}
+
+		for _, child := range value {
+			if err := assertLocalReferences(child); err != nil {
+				return err
+			}
+		}
+
+	case []interface{}:
+		for _, child := range value {
+			if err := assertLocalReferences(child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func putTemplate(ctx contractapi.TransactionContextInterface, templateKey string, template *CertificateTemplate) error {
+	dataBytes, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(templateKey, dataBytes)
+}
+
+// assertIssuerIdentity verifies the submitting client is enrolled on behalf of issuerId
+// and the issuer is active in issuer registry under the client MSP
+func assertIssuerIdentity(ctx contractapi.TransactionContextInterface, issuerId string) (*IssuerRecord, error) {
+	if issuerId == "" {
+		return nil, fmt.Errorf("Issuer id must not be empty")
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	clientIssuerId, found, err := ctx.GetClientIdentity().GetAttributeValue(IssuerIdAttribute)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read client attribute %s. %s", IssuerIdAttribute, err.Error())
+	}
+
+	if !found || clientIssuerId != issuerId {
+		return nil, fmt.Errorf("Client identity of %s is not authorized to act on behalf of issuer %s", mspId, issuerId)
+	}
+
+	issuer, err := queryIssuer(ctx, issuerId)
+	if err != nil {
+		return nil, err
+	}
+
+	if issuer.MspId != mspId {
+		return nil, fmt.Errorf("Issuer %s is not registered under %s", issuerId, mspId)
+	}
+
+	if issuer.Status != IssuerActive {
+		return nil, fmt.Errorf("Issuer %s is not active. Status: %s", issuerId, issuer.Status)
+	}
+
+	return issuer, nil
+}
+
+// queryIssuer read issuer from issuer registry chaincode
+func queryIssuer(ctx contractapi.TransactionContextInterface, issuerId string) (*IssuerRecord, error) {
+	args := [][]byte{[]byte("QueryIssuer"), []byte(issuerId)}
+	response := ctx.GetStub().InvokeChaincode(IssuerRegistryChaincode, args, "")
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("Failed to query issuer registry. %s", response.Message)
+	}
+
+	issuer := new(IssuerRecord)
+	err := json.Unmarshal(response.Payload, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return issuer, nil
+}
+
 func main() {
 	chaincode, err := contractapi.NewChaincode(new(SmartContract))
 
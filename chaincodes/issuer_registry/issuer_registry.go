@@ -0,0 +1,457 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+type SmartContract struct {
+	contractapi.Contract
+}
+
+const (
+	// RegistryAdminAttribute is the X.509 attribute granting permission to manage issuers
+	RegistryAdminAttribute = "registry_admin"
+	// IssuerIdAttribute is the X.509 attribute binding an enrolled identity to issuer id
+	IssuerIdAttribute = "issuer_id"
+
+	// configObjectType is the composite key object type of registry configuration, kept apart from issuer ids
+	configObjectType = "config"
+	platformMspIdKey = "platform_msp_id"
+
+	IssuerActive    = "ACTIVE"
+	IssuerSuspended = "SUSPENDED"
+)
+
+// Signature algorithms of issuer public key, verified by certificate info
+const (
+	KeyAlgorithmECDSAP256 = "ECDSA_P256"
+	KeyAlgorithmEd25519   = "ED25519"
+)
+
+// issuerId: issuer id (uuid) referenced by issuer_id in certificate info and certificate template
+// mspId: MSP ID of organization allowed to submit transactions on behalf of issuer
+// issuerName: display name of academic institution
+// publicKeys: public signing keys of issuer used to sign certificates
+// accreditationStatus: accreditation status of academic institution (example: ACCREDITED, PENDING, EXPIRED)
+// status: ACTIVE or SUSPENDED. Suspended issuer cannot issue certificate or template
+// registeredAt: transaction time issuer registered
+
+// IssuerRecord describes academic institution registered on the platform
+type IssuerRecord struct {
+	MspId               string            `json:"msp_id"`
+	IssuerName          string            `json:"issuer_name"`
+	PublicKeys          []IssuerPublicKey `json:"public_keys"`
+	AccreditationStatus string            `json:"accreditation_status"`
+	Status              string            `json:"status"`
+	RegisteredAt        int64             `json:"registered_at"`
+}
+
+// keyId: identifier of public key, referenced by signer
+// algorithm: signature algorithm of the key (ECDSA_P256 or ED25519)
+// publicKey: PEM encoded PKIX public key
+// isRevoked: revoked key cannot be used for verifying new signature
+
+// IssuerPublicKey describes public signing key of issuer
+type IssuerPublicKey struct {
+	KeyId     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+	IsRevoked bool   `json:"is_revoked"`
+}
+
+// HistoryQueryResult used for handling result modification history of issuer
+type HistoryQueryResult struct {
+	Value     *IssuerRecord `json:"value"`
+	TxId      string        `json:"txid"`
+	Timestamp time.Time     `json:"timestamp"`
+	IsDelete  bool          `json:"is_delete"`
+}
+
+// InitRegistry set MSP ID of platform organization once, at deployment. Admin attributes of all chaincodes on the channel
+// are only trusted on identities of this MSP, as CA of other organizations can issue any attribute.
+// Only identity of platformMspId allowed, fails if platform MSP ID already set.
+func (s *SmartContract) InitRegistry(ctx contractapi.TransactionContextInterface, platformMspId string) error {
+	if platformMspId == "" {
+		return fmt.Errorf("Platform MSP ID must not be empty")
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	if mspId != platformMspId {
+		return fmt.Errorf("Client identity of %s cannot set platform MSP ID %s", mspId, platformMspId)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{platformMspIdKey})
+	if err != nil {
+		return err
+	}
+
+	dataBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if dataBytes != nil {
+		return fmt.Errorf("Platform MSP ID already set to %s", string(dataBytes))
+	}
+
+	return ctx.GetStub().PutState(key, []byte(platformMspId))
+}
+
+// GetPlatformMspId returns MSP ID of platform organization set by InitRegistry
+func (s *SmartContract) GetPlatformMspId(ctx contractapi.TransactionContextInterface) (string, error) {
+	return getPlatformMspId(ctx)
+}
+
+// RegisterIssuer add new issuer into registry. Only registry admin allowed.
+func (s *SmartContract) RegisterIssuer(ctx contractapi.TransactionContextInterface,
+	issuerId, mspId, issuerName, accreditationStatus string) error {
+
+	if err := assertRegistryAdmin(ctx); err != nil {
+		return err
+	}
+
+	if issuerId == "" || mspId == "" || issuerName == "" {
+		return fmt.Errorf("Issuer id, MSP ID and issuer name must not be empty")
+	}
+
+	issuer, _ := s.QueryIssuer(ctx, issuerId)
+	if issuer != nil {
+		return fmt.Errorf("Issuer %s already registered", issuerId)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	issuer = &IssuerRecord{
+		MspId:               mspId,
+		IssuerName:          issuerName,
+		PublicKeys:          []IssuerPublicKey{},
+		AccreditationStatus: accreditationStatus,
+		Status:              IssuerActive,
+		RegisteredAt:        txTimestamp.Seconds,
+	}
+
+	return putIssuer(ctx, issuerId, issuer)
+}
+
+// UpdateIssuerName change display name of issuer. Only registry admin allowed.
+func (s *SmartContract) UpdateIssuerName(ctx contractapi.TransactionContextInterface, issuerId, issuerName string) error {
+	if err := assertRegistryAdmin(ctx); err != nil {
+		return err
+	}
+
+	if issuerName == "" {
+		return fmt.Errorf("Issuer name must not be empty")
+	}
+
+	issuer, err := s.QueryIssuer(ctx, issuerId)
+	if err != nil {
+		return err
+	}
+
+	issuer.IssuerName = issuerName
+
+	return putIssuer(ctx, issuerId, issuer)
+}
+
+// SetAccreditationStatus change accreditation status of issuer. Only registry admin allowed.
+func (s *SmartContract) SetAccreditationStatus(ctx contractapi.TransactionContextInterface, issuerId, accreditationStatus string) error {
+	if err := assertRegistryAdmin(ctx); err != nil {
+		return err
+	}
+
+	issuer, err := s.QueryIssuer(ctx, issuerId)
+	if err != nil {
+		return err
+	}
+
+	issuer.AccreditationStatus = accreditationStatus
+
+	return putIssuer(ctx, issuerId, issuer)
+}
+
+// SuspendIssuer suspend issuer from issuing certificate and template. Only registry admin allowed.
+func (s *SmartContract) SuspendIssuer(ctx contractapi.TransactionContextInterface, issuerId string) error {
+	return s.setIssuerStatus(ctx, issuerId, IssuerSuspended)
+}
+
+// ReactivateIssuer reactivate suspended issuer. Only registry admin allowed.
+func (s *SmartContract) ReactivateIssuer(ctx contractapi.TransactionContextInterface, issuerId string) error {
+	return s.setIssuerStatus(ctx, issuerId, IssuerActive)
+}
+
+func (s *SmartContract) setIssuerStatus(ctx contractapi.TransactionContextInterface, issuerId, status string) error {
+	if err := assertRegistryAdmin(ctx); err != nil {
+		return err
+	}
+
+	issuer, err := s.QueryIssuer(ctx, issuerId)
+	if err != nil {
+		return err
+	}
+
+	if issuer.Status == status {
+		return fmt.Errorf("Issuer %s already %s", issuerId, status)
+	}
+
+	issuer.Status = status
+
+	return putIssuer(ctx, issuerId, issuer)
+}
+
+// AddPublicKey register public signing key of issuer. Allowed for registry admin or the issuer itself.
+// Public key must be PEM encoded PKIX key matching algorithm (ECDSA_P256 or ED25519).
+func (s *SmartContract) AddPublicKey(ctx contractapi.TransactionContextInterface, issuerId, keyId, algorithm, publicKey string) error {
+	issuer, err := s.QueryIssuer(ctx, issuerId)
+	if err != nil {
+		return err
+	}
+
+	if err := assertIssuerOrAdmin(ctx, issuerId, issuer); err != nil {
+		return err
+	}
+
+	if keyId == "" || algorithm == "" || publicKey == "" {
+		return fmt.Errorf("Key id, algorithm and public key must not be empty")
+	}
+
+	if err := validatePublicKey(algorithm, publicKey); err != nil {
+		return err
+	}
+
+	for _, key := range issuer.PublicKeys {
+		if key.KeyId == keyId {
+			return fmt.Errorf("Key %s already registered for issuer %s", keyId, issuerId)
+		}
+	}
+
+	issuer.PublicKeys = append(issuer.PublicKeys, IssuerPublicKey{
+		KeyId:     keyId,
+		Algorithm: algorithm,
+		PublicKey: publicKey,
+		IsRevoked: false,
+	})
+
+	return putIssuer(ctx, issuerId, issuer)
+}
+
+// RevokePublicKey revoke public signing key of issuer. Allowed for registry admin or the issuer itself.
+func (s *SmartContract) RevokePublicKey(ctx contractapi.TransactionContextInterface, issuerId, keyId string) error {
+	issuer, err := s.QueryIssuer(ctx, issuerId)
+	if err != nil {
+		return err
+	}
+
+	if err := assertIssuerOrAdmin(ctx, issuerId, issuer); err != nil {
+		return err
+	}
+
+	for i := range issuer.PublicKeys {
+		if issuer.PublicKeys[i].KeyId != keyId {
+			continue
+		}
+
+		if issuer.PublicKeys[i].IsRevoked {
+			return fmt.Errorf("Key %s already revoked", keyId)
+		}
+
+		issuer.PublicKeys[i].IsRevoked = true
+		return putIssuer(ctx, issuerId, issuer)
+	}
+
+	return fmt.Errorf("Key %s does not exist for issuer %s", keyId, issuerId)
+}
+
+// QueryIssuer returns the issuer stored in the world state with given id
+func (s *SmartContract) QueryIssuer(ctx contractapi.TransactionContextInterface, issuerId string) (*IssuerRecord, error) {
+	dataBytes, err := ctx.GetStub().GetState(issuerId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if dataBytes == nil {
+		return nil, fmt.Errorf("Issuer %s does not exist", issuerId)
+	}
+
+	issuer := new(IssuerRecord)
+	err = json.Unmarshal(dataBytes, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return issuer, nil
+}
+
+// GetHistoryForKey get modification history of issuer
+func (s *SmartContract) GetHistoryForKey(ctx contractapi.TransactionContextInterface, issuerId string) ([]HistoryQueryResult, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(issuerId)
+	if err != nil {
+		return []HistoryQueryResult{}, err
+	}
+
+	defer resultsIterator.Close()
+
+	results := []HistoryQueryResult{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		record := new(IssuerRecord)
+		err = json.Unmarshal(queryResponse.Value, record)
+		if err != nil {
+			return nil, err
+		}
+
+		timestamp := time.Unix(int64(queryResponse.Timestamp.Seconds), int64(queryResponse.Timestamp.Nanos))
+
+		r := HistoryQueryResult{
+			Value:     record,
+			TxId:      queryResponse.TxId,
+			Timestamp: timestamp,
+			IsDelete:  queryResponse.IsDelete,
+		}
+
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+func putIssuer(ctx contractapi.TransactionContextInterface, issuerId string, issuer *IssuerRecord) error {
+	dataBytes, err := json.Marshal(issuer)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(issuerId, dataBytes)
+}
+
+// validatePublicKey verifies PEM encoded public key is PKIX key of the algorithm, so mismatch is rejected at registration
+// instead of failing every certificate signature check later
+func validatePublicKey(algorithm, publicKeyPem string) error {
+	if algorithm != KeyAlgorithmECDSAP256 && algorithm != KeyAlgorithmEd25519 {
+		return fmt.Errorf("Unsupported key algorithm: %s", algorithm)
+	}
+
+	block, _ := pem.Decode([]byte(publicKeyPem))
+	if block == nil {
+		return fmt.Errorf("Public key is not PEM encoded")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("Failed to parse public key. %s", err.Error())
+	}
+
+	switch algorithm {
+	case KeyAlgorithmECDSAP256:
+		ecdsaKey, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok || ecdsaKey.Curve != elliptic.P256() {
+			return fmt.Errorf("Public key is not ECDSA P-256 key")
+		}
+
+	case KeyAlgorithmEd25519:
+		if _, ok := publicKey.(ed25519.PublicKey); !ok {
+			return fmt.Errorf("Public key is not Ed25519 key")
+		}
+	}
+
+	return nil
+}
+
+// getPlatformMspId read MSP ID of platform organization set by InitRegistry
+func getPlatformMspId(ctx contractapi.TransactionContextInterface) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{platformMspIdKey})
+	if err != nil {
+		return "", err
+	}
+
+	dataBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if dataBytes == nil {
+		return "", fmt.Errorf("Platform MSP ID is not set, call InitRegistry")
+	}
+
+	return string(dataBytes), nil
+}
+
+// assertRegistryAdmin verifies the submitting client is platform organization identity with registry admin attribute
+func assertRegistryAdmin(ctx contractapi.TransactionContextInterface) error {
+	platformMspId, err := getPlatformMspId(ctx)
+	if err != nil {
+		return err
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	if mspId != platformMspId {
+		return fmt.Errorf("Client identity of %s is not registry admin", mspId)
+	}
+
+	err = ctx.GetClientIdentity().AssertAttributeValue(RegistryAdminAttribute, "true")
+	if err != nil {
+		return fmt.Errorf("Client identity is not registry admin. %s", err.Error())
+	}
+
+	return nil
+}
+
+// assertIssuerOrAdmin verifies the submitting client is registry admin, or enrolled on behalf of issuer under its MSP
+func assertIssuerOrAdmin(ctx contractapi.TransactionContextInterface, issuerId string, issuer *IssuerRecord) error {
+	if assertRegistryAdmin(ctx) == nil {
+		return nil
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	clientIssuerId, found, err := ctx.GetClientIdentity().GetAttributeValue(IssuerIdAttribute)
+	if err != nil {
+		return fmt.Errorf("Failed to read client attribute %s. %s", IssuerIdAttribute, err.Error())
+	}
+
+	if !found || clientIssuerId != issuerId || mspId != issuer.MspId {
+		return fmt.Errorf("Client identity of %s is not authorized to manage issuer %s", mspId, issuerId)
+	}
+
+	return nil
+}
+
+func main() {
+	chaincode, err := contractapi.NewChaincode(new(SmartContract))
+
+	if err != nil {
+		fmt.Printf("Error create IssuerRegistry chaincode: %s", err.Error())
+		return
+	}
+
+	if err := chaincode.Start(); err != nil {
+		fmt.Printf("Error starting IssuerRegistry chaincode: %s", err.Error())
+	}
+}
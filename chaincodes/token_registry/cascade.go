@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// MaxCascadeBatchSize is maximum number of descendant tokens revoked per transaction by RevokeTokenCascade
+	MaxCascadeBatchSize = 500
+
+	cascadeObjectType = "revocation_cascade"
+)
+
+// CascadeRevocationResult describes progress of cascading revocation
+type CascadeRevocationResult struct {
+	TokenId   string `json:"token_id"`
+	Revoked   int    `json:"revoked"`
+	Pending   int    `json:"pending"`
+	Completed bool   `json:"completed"`
+}
+
+// cascadeRevocation describes state of cascading revocation carried over to the next transaction.
+// Pending lists revoked tokens whose descendants are not fully revoked yet. Revoked lists tokens revoked by
+// the current transaction, whose writes are not visible to its own reads; it is not carried over.
+type cascadeRevocation struct {
+	Pending []string `json:"pending"`
+	revoked map[string]bool
+}
+
+// RevokeTokenCascade revoke tokenId and all tokens issued from it (linked through issuer_ref) or transferred out of it
+// by TransferToken, at most batchSize descendants per transaction. If result is not completed, call again with
+// the same tokenId to continue. Tokens issued before the links were introduced are only reached after
+// BackfillTokenIndexes. Only the token owner allowed.
+func (s *SmartContract) RevokeTokenCascade(ctx contractapi.TransactionContextInterface, tokenId string, batchSize int) (*CascadeRevocationResult, error) {
+	if batchSize <= 0 || batchSize > MaxCascadeBatchSize {
+		return nil, fmt.Errorf("Batch size must be between 1 and %d", MaxCascadeBatchSize)
+	}
+
+	token, err := s.QueryToken(ctx, tokenId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = assertTokenOwner(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	cascadeKey, err := ctx.GetStub().CreateCompositeKey(cascadeObjectType, []string{tokenId})
+	if err != nil {
+		return nil, err
+	}
+
+	cascadeBytes, err := ctx.GetStub().GetState(cascadeKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	cascade := &cascadeRevocation{Pending: []string{tokenId}}
+	if cascadeBytes != nil {
+		err = json.Unmarshal(cascadeBytes, cascade)
+		if err != nil {
+			return nil, err
+		}
+	} else if !token.IsRevoked {
+		token.IsRevoked = true
+
+		tokenBytes, err := json.Marshal(token)
+		if err != nil {
+			return nil, err
+		}
+
+		err = ctx.GetStub().PutState(tokenId, tokenBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cascade.revoked = map[string]bool{tokenId: true}
+	result := &CascadeRevocationResult{TokenId: tokenId}
+
+	for len(cascade.Pending) > 0 && result.Revoked < batchSize {
+		exhausted, err := revokeChildren(ctx, cascade, batchSize, result)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exhausted {
+			break
+		}
+
+		cascade.Pending = cascade.Pending[1:]
+	}
+
+	result.Pending = len(cascade.Pending)
+	result.Completed = result.Pending == 0
+
+	if result.Completed {
+		if cascadeBytes == nil {
+			return result, nil
+		}
+
+		return result, ctx.GetStub().DelState(cascadeKey)
+	}
+
+	cascadeBytes, err = json.Marshal(cascade)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, ctx.GetStub().PutState(cascadeKey, cascadeBytes)
+}
+
+// revokeChildren revoke non-revoked tokens issued from or transferred out of the first pending token until batch is full.
+// Transferable children are added to pending, standard tokens cannot issue further tokens.
+// Returns true if all children revoked.
+func revokeChildren(ctx contractapi.TransactionContextInterface, cascade *cascadeRevocation, batchSize int, result *CascadeRevocationResult) (bool, error) {
+	exhausted, err := revokeLinkedTokens(ctx, issuedTokenIndex, cascade, batchSize, result)
+	if err != nil || !exhausted {
+		return exhausted, err
+	}
+
+	return revokeLinkedTokens(ctx, transferRecipientIndex, cascade, batchSize, result)
+}
+
+// revokeLinkedTokens revoke non-revoked tokens linked from the first pending token through index
+// (issued~from~to or transfer~from~to). Key range is re-validated at commit, so token linked by concurrent
+// transaction fails this transaction instead of being skipped.
+// Recipient token accumulating transfers of several tokens is revoked with any of them.
+func revokeLinkedTokens(ctx contractapi.TransactionContextInterface, index string, cascade *cascadeRevocation,
+	batchSize int, result *CascadeRevocationResult) (bool, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{cascade.Pending[0]})
+	if err != nil {
+		return false, err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		if result.Revoked == batchSize {
+			return false, nil
+		}
+
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return false, err
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return false, err
+		}
+
+		childId := keyParts[1]
+		childBytes, err := ctx.GetStub().GetState(childId)
+		if err != nil {
+			return false, fmt.Errorf("Failed to read from world state. %s", err.Error())
+		}
+
+		if childBytes == nil {
+			continue
+		}
+
+		child := new(AccessTokenRegistry)
+		err = json.Unmarshal(childBytes, child)
+		if err != nil {
+			return false, err
+		}
+
+		// Token linked through both issuer_ref and transfer, or through transfer cycle, may be revoked already
+		if child.IsRevoked || cascade.revoked[childId] {
+			continue
+		}
+
+		err = revokeDescendant(ctx, childId, child, cascade, result)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// revokeDescendant revoke token found by cascade, transferable token is added to pending
+func revokeDescendant(ctx contractapi.TransactionContextInterface, tokenId string, token *AccessTokenRegistry,
+	cascade *cascadeRevocation, result *CascadeRevocationResult) error {
+
+	token.IsRevoked = true
+
+	tokenBytes, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(tokenId, tokenBytes)
+	if err != nil {
+		return err
+	}
+
+	cascade.revoked[tokenId] = true
+	result.Revoked++
+
+	if token.Transferable {
+		cascade.Pending = append(cascade.Pending, tokenId)
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+const (
+	// CertificateInfoChaincode is the chaincode name of certificate info on the same channel
+	CertificateInfoChaincode = "certificate_info"
+
+	// MaxCertificateRevocationBatchSize is maximum number of tokens revoked per transaction by RevokeTokensForCertificate
+	MaxCertificateRevocationBatchSize = 500
+
+	// maxSupersedeChainLength bounds number of re-issuances followed to the current certificate version,
+	// same as GetCurrentCertificate of certificate info
+	maxSupersedeChainLength = 100
+
+	certificateRevoked   = "REVOKED"
+	certificateSuspended = "SUSPENDED"
+)
+
+// CertificateRevocationResult describes progress of revoking tokens of certificate
+type CertificateRevocationResult struct {
+	CertificateId string `json:"certificate_id"`
+	Revoked       int    `json:"revoked"`
+	Completed     bool   `json:"completed"`
+}
+
+// certificateRecord describes certificate fields read from certificate info chaincode
+type certificateRecord struct {
+	Status       string `json:"status"`
+	SupersededBy string `json:"superseded_by"`
+	IssuerId     string `json:"issuer_id"`
+	IssuerMsp    string `json:"issuer_msp"`
+}
+
+// RevokeTokensForCertificate revoke tokens of certificateId once the current version of the certificate is revoked in
+// certificate info, at most batchSize tokens per transaction. If result is not completed, call again to continue.
+// Suspension is temporary, tokens of suspended certificate are only blocked.
+// Invoked by RevokeCertificate of certificate info in the revoking transaction, which cannot read the revoked status
+// it is writing, so the status is only checked for other clients (example: resubmitting remaining batches).
+func (s *SmartContract) RevokeTokensForCertificate(ctx contractapi.TransactionContextInterface,
+	certificateId string, batchSize int) (*CertificateRevocationResult, error) {
+
+	if batchSize <= 0 || batchSize > MaxCertificateRevocationBatchSize {
+		return nil, fmt.Errorf("Batch size must be between 1 and %d", MaxCertificateRevocationBatchSize)
+	}
+
+	invokedByCertificateInfo, err := isProposalForChaincode(ctx, CertificateInfoChaincode)
+	if err != nil {
+		return nil, err
+	}
+
+	if !invokedByCertificateInfo {
+		certificate, err := queryCurrentCertificate(ctx, certificateId)
+		if err != nil {
+			return nil, err
+		}
+
+		if certificate == nil {
+			return nil, fmt.Errorf("Certificate %s does not exist in certificate info", certificateId)
+		}
+
+		if certificate.Status != certificateRevoked {
+			return nil, fmt.Errorf("Certificate %s is not revoked. Status: %s", certificateId, certificate.Status)
+		}
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(certificateTokenIndex, []string{certificateId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	result := &CertificateRevocationResult{CertificateId: certificateId}
+
+	for resultsIterator.HasNext() {
+		if result.Revoked == batchSize {
+			return result, nil
+		}
+
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		tokenId := keyParts[1]
+		tokenBytes, err := ctx.GetStub().GetState(tokenId)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+		}
+
+		if tokenBytes == nil {
+			continue
+		}
+
+		token := new(AccessTokenRegistry)
+		err = json.Unmarshal(tokenBytes, token)
+		if err != nil {
+			return nil, err
+		}
+
+		// Tokens revoked in previous batches stay in the index
+		if token.IsRevoked {
+			continue
+		}
+
+		token.IsRevoked = true
+
+		tokenBytes, err = json.Marshal(token)
+		if err != nil {
+			return nil, err
+		}
+
+		err = ctx.GetStub().PutState(tokenId, tokenBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Revoked++
+	}
+
+	result.Completed = true
+
+	return result, nil
+}
+
+// assertCertificateNotRevoked verifies current version of certificate of token is neither revoked nor suspended
+// in certificate info chaincode. Certificates without record (example: anchored in cohort, checked through
+// VerifyInclusion of certificate info) are allowed.
+func assertCertificateNotRevoked(ctx contractapi.TransactionContextInterface, certificateId string) error {
+	certificateStatus, err := checkCertificateStatus(ctx, certificateId)
+	if err != nil {
+		return err
+	}
+
+	if certificateStatus != "VALID" {
+		return fmt.Errorf("Certificate %s is not valid. Status: %s", certificateId, certificateStatus)
+	}
+
+	return nil
+}
+
+// checkCertificateStatus returns token status given by current version of certificate in certificate info.
+// - Certificate revoked: certificate has been revoked, its tokens are revoked by RevokeTokensForCertificate.
+// - Certificate suspended: certificate is suspended, its tokens cannot be used until certificate is reinstated.
+// - Valid: certificate does not prevent use of its tokens, or has no record in certificate info.
+func checkCertificateStatus(ctx contractapi.TransactionContextInterface, certificateId string) (string, error) {
+	certificate, err := queryCurrentCertificate(ctx, certificateId)
+	if err != nil {
+		return "", err
+	}
+
+	if certificate != nil {
+		switch certificate.Status {
+		case certificateRevoked:
+			return "CERTIFICATE_REVOKED", nil
+		case certificateSuspended:
+			return "CERTIFICATE_SUSPENDED", nil
+		}
+	}
+
+	return "VALID", nil
+}
+
+// queryCurrentCertificate follows supersede chain from certificateId and returns the latest version of certificate,
+// or nil if certificateId has no record in certificate info
+func queryCurrentCertificate(ctx contractapi.TransactionContextInterface, certificateId string) (*certificateRecord, error) {
+	certificate, err := queryCertificate(ctx, certificateId)
+	if err != nil || certificate == nil {
+		return nil, err
+	}
+
+	for i := 0; certificate.SupersededBy != ""; i++ {
+		if i == maxSupersedeChainLength {
+			return nil, fmt.Errorf("Certificate %s supersede chain exceeds %d re-issuances", certificateId, maxSupersedeChainLength)
+		}
+
+		supersededBy := certificate.SupersededBy
+		certificate, err = queryCertificate(ctx, supersededBy)
+		if err != nil {
+			return nil, err
+		}
+
+		if certificate == nil {
+			return nil, fmt.Errorf("Certificate %s superseding %s does not exist", supersededBy, certificateId)
+		}
+	}
+
+	return certificate, nil
+}
+
+// queryCertificate read certificate from certificate info chaincode, or nil if the certificate does not exist
+func queryCertificate(ctx contractapi.TransactionContextInterface, certificateId string) (*certificateRecord, error) {
+	args := [][]byte{[]byte("CertificateExists"), []byte(certificateId)}
+	response := ctx.GetStub().InvokeChaincode(CertificateInfoChaincode, args, "")
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("Failed to query certificate info. %s", response.Message)
+	}
+
+	var exists bool
+	err := json.Unmarshal(response.Payload, &exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	args = [][]byte{[]byte("QueryCertificate"), []byte(certificateId)}
+	response = ctx.GetStub().InvokeChaincode(CertificateInfoChaincode, args, "")
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("Failed to query certificate info. %s", response.Message)
+	}
+
+	certificate := new(certificateRecord)
+	err = json.Unmarshal(response.Payload, certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	return certificate, nil
+}
+
+// isProposalForChaincode returns true when the transaction proposal invokes chaincodeName, which then invokes this
+// chaincode. Proposal is signed by the client and endorsed by executing chaincodeName, so its caller cannot be forged.
+func isProposalForChaincode(ctx contractapi.TransactionContextInterface, chaincodeName string) (bool, error) {
+	signedProposal, err := ctx.GetStub().GetSignedProposal()
+	if err != nil {
+		return false, err
+	}
+
+	if signedProposal == nil {
+		return false, nil
+	}
+
+	proposal := new(peer.Proposal)
+	err = proto.Unmarshal(signedProposal.ProposalBytes, proposal)
+	if err != nil {
+		return false, fmt.Errorf("Failed to read transaction proposal. %s", err.Error())
+	}
+
+	payload := new(peer.ChaincodeProposalPayload)
+	err = proto.Unmarshal(proposal.Payload, payload)
+	if err != nil {
+		return false, fmt.Errorf("Failed to read transaction proposal. %s", err.Error())
+	}
+
+	invocation := new(peer.ChaincodeInvocationSpec)
+	err = proto.Unmarshal(payload.Input, invocation)
+	if err != nil {
+		return false, fmt.Errorf("Failed to read transaction proposal. %s", err.Error())
+	}
+
+	chaincodeId := invocation.GetChaincodeSpec().GetChaincodeId()
+
+	return chaincodeId.GetName() == chaincodeName, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Clock provides current time to time-dependent token rules (expiry and quota replenishment)
+type Clock interface {
+	Now() time.Time
+}
+
+// fixedClock always returns the same time
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+// newTxClock returns clock fixed at transaction timestamp, so every endorsing peer computes the same result
+func newTxClock(ctx contractapi.TransactionContextInterface) (Clock, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	return fixedClock{now: time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC()}, nil
+}
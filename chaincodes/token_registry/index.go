@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// issuedTokenIndex links issuer token (issuer_ref) to tokens issued from it, so cascading revocation reaches them
+// through key range re-validated at commit on both LevelDB and CouchDB peers
+const issuedTokenIndex = "issued~from~to"
+
+// certificateTokenIndex links certificate to its tokens, so RevokeTokensForCertificate reaches them
+// the same way
+const certificateTokenIndex = "certificate~token"
+
+// BackfillResult describes progress of index backfill
+type BackfillResult struct {
+	Indexed   int    `json:"indexed"`
+	NextKey   string `json:"next_key"`
+	Completed bool   `json:"completed"`
+}
+
+// BackfillTokenIndexes write index entries of tokens issued before the indexes were introduced, at most limit tokens
+// per transaction starting from startKey (empty for the first call). If result is not completed, call again with returned
+// next key. Entries are rewritten as is, so running it over indexed tokens is harmless. Only platform service allowed.
+func (s *SmartContract) BackfillTokenIndexes(ctx contractapi.TransactionContextInterface, startKey string, limit int) (*BackfillResult, error) {
+	err := assertPlatformAttribute(ctx, TokenServiceAttribute, "token service")
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > MaxPageSize {
+		return nil, fmt.Errorf("Limit must be between 1 and %d", MaxPageSize)
+	}
+
+	// Range query skips composite keys (index entries and cascade state)
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	result := &BackfillResult{}
+
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Indexed == limit {
+			result.NextKey = queryResult.Key
+			return result, nil
+		}
+
+		token := new(AccessTokenRegistry)
+		err = json.Unmarshal(queryResult.Value, token)
+		if err != nil {
+			return nil, err
+		}
+
+		err = putTokenIndexes(ctx, queryResult.Key, token)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Indexed++
+	}
+
+	result.Completed = true
+
+	return result, nil
+}
+
+// putTokenIndexes write index entries of token
+func putTokenIndexes(ctx contractapi.TransactionContextInterface, tokenId string, token *AccessTokenRegistry) error {
+	if token.CertificateId != "" {
+		err := putIndexEntry(ctx, certificateTokenIndex, token.CertificateId, tokenId)
+		if err != nil {
+			return err
+		}
+	}
+
+	if token.IssuerRef == "" {
+		return nil
+	}
+
+	return putIndexEntry(ctx, issuedTokenIndex, token.IssuerRef, tokenId)
+}
+
+// putIndexEntry write composite key linking from to to
+func putIndexEntry(ctx contractapi.TransactionContextInterface, index, from, to string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(index, []string{from, to})
+	if err != nil {
+		return err
+	}
+
+	// Composite key carries the link, empty value would delete the key
+	return ctx.GetStub().PutState(key, []byte{0x00})
+}
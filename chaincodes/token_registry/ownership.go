@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Identity attributes are only trusted on identities of platform MSP (GetPlatformMspId of issuer registry),
+// CA of other organizations can issue any attribute.
+const (
+	// OwnerEmailAttribute is the X.509 attribute (registered through Fabric CA) binding an enrolled identity to owner email
+	OwnerEmailAttribute = "email"
+	// TokenServiceAttribute is the X.509 attribute designating platform service allowed to act on any token
+	TokenServiceAttribute = "token_service"
+	// IssuerIdAttribute is the X.509 attribute binding an enrolled identity of issuer organization to issuer id
+	IssuerIdAttribute = "issuer_id"
+)
+
+// ApproveOperator delegate operator (email) to consume and change owner of tokenId on behalf of owner.
+// Only the token owner allowed.
+func (s *SmartContract) ApproveOperator(ctx contractapi.TransactionContextInterface, tokenId, operator string) error {
+	token, err := s.QueryToken(ctx, tokenId)
+	if err != nil {
+		return err
+	}
+
+	err = assertTokenOwner(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	operator = normalizeEmail(operator)
+	if operator == "" {
+		return fmt.Errorf("Operator must not be empty")
+	}
+
+	if isOperator(token, operator) {
+		return fmt.Errorf("%s already operator of TokenId %s", operator, tokenId)
+	}
+
+	token.Operators = append(token.Operators, operator)
+
+	tokenBytes, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(tokenId, tokenBytes)
+}
+
+// RevokeOperator remove delegated operator (email) of tokenId. Only the token owner allowed.
+func (s *SmartContract) RevokeOperator(ctx contractapi.TransactionContextInterface, tokenId, operator string) error {
+	token, err := s.QueryToken(ctx, tokenId)
+	if err != nil {
+		return err
+	}
+
+	err = assertTokenOwner(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	operator = normalizeEmail(operator)
+	if !isOperator(token, operator) {
+		return fmt.Errorf("%s is not operator of TokenId %s", operator, tokenId)
+	}
+
+	operators := []string{}
+	for _, o := range token.Operators {
+		if o != operator {
+			operators = append(operators, o)
+		}
+	}
+	token.Operators = operators
+
+	tokenBytes, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(tokenId, tokenBytes)
+}
+
+// assertTokenOwner verifies the submitting client is owner of token or platform service
+func assertTokenOwner(ctx contractapi.TransactionContextInterface, token *AccessTokenRegistry) error {
+	email, isService, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if isService || (email != "" && email == normalizeEmail(token.Owner)) {
+		return nil
+	}
+
+	return fmt.Errorf("Client identity is not owner of TokenId %s", token.TokenId)
+}
+
+// assertTokenOwnerOrIssuer verifies the submitting client is owner or issuer of token, or platform service
+func assertTokenOwnerOrIssuer(ctx contractapi.TransactionContextInterface, token *AccessTokenRegistry) error {
+	email, isService, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if isService || (email != "" && (email == normalizeEmail(token.Owner) || email == normalizeEmail(token.Issuer))) {
+		return nil
+	}
+
+	return fmt.Errorf("Client identity is not owner or issuer of TokenId %s", token.TokenId)
+}
+
+// assertCertificateIssuer verifies the submitting client is platform service, or enrolled on behalf of issuer of
+// certificate under its MSP (as checked by certificate info)
+func assertCertificateIssuer(ctx contractapi.TransactionContextInterface, certificateId string, certificate *certificateRecord) error {
+	_, isService, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if isService {
+		return nil
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	clientIssuerId, found, err := ctx.GetClientIdentity().GetAttributeValue(IssuerIdAttribute)
+	if err != nil {
+		return fmt.Errorf("Failed to read client attribute %s. %s", IssuerIdAttribute, err.Error())
+	}
+
+	if !found || certificate.IssuerId == "" || clientIssuerId != certificate.IssuerId || mspId != certificate.IssuerMsp {
+		return fmt.Errorf("Client identity of %s is not platform service or issuer of certificate %s", mspId, certificateId)
+	}
+
+	return nil
+}
+
+// assertTokenController verifies the submitting client is owner, issuer or delegated operator of token, or platform service
+func assertTokenController(ctx contractapi.TransactionContextInterface, token *AccessTokenRegistry) error {
+	email, isService, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if isService {
+		return nil
+	}
+
+	if email != "" && (email == normalizeEmail(token.Owner) || email == normalizeEmail(token.Issuer) || isOperator(token, email)) {
+		return nil
+	}
+
+	return fmt.Errorf("Client identity is not owner, issuer or operator of TokenId %s", token.TokenId)
+}
+
+// getClientIdentity returns email bound to the submitting client and whether client is platform service.
+// Identities of other organizations have neither.
+func getClientIdentity(ctx contractapi.TransactionContextInterface) (string, bool, error) {
+	platformMspId, err := queryPlatformMspId(ctx)
+	if err != nil {
+		return "", false, err
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", false, fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	if mspId != platformMspId {
+		return "", false, nil
+	}
+
+	email, _, err := ctx.GetClientIdentity().GetAttributeValue(OwnerEmailAttribute)
+	if err != nil {
+		return "", false, fmt.Errorf("Failed to read client attribute %s. %s", OwnerEmailAttribute, err.Error())
+	}
+
+	isService := assertPlatformAttribute(ctx, TokenServiceAttribute, "token service") == nil
+
+	return normalizeEmail(email), isService, nil
+}
+
+// queryPlatformMspId read MSP ID of platform organization from issuer registry chaincode
+func queryPlatformMspId(ctx contractapi.TransactionContextInterface) (string, error) {
+	args := [][]byte{[]byte("GetPlatformMspId")}
+	response := ctx.GetStub().InvokeChaincode(IssuerRegistryChaincode, args, "")
+	if response.Status != shim.OK {
+		return "", fmt.Errorf("Failed to query issuer registry. %s", response.Message)
+	}
+
+	return string(response.Payload), nil
+}
+
+func isOperator(token *AccessTokenRegistry, email string) bool {
+	for _, operator := range token.Operators {
+		if operator == email {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// QueryAdminAttribute is the X.509 attribute granting permission to run raw state database queries
+	QueryAdminAttribute = "query_admin"
+
+	// DefaultQueryLimit is number of records returned by QueryRecordsByFilter when limit not specified
+	DefaultQueryLimit = 100
+)
+
+// queryIndex describes CouchDB index in META-INF/statedb/couchdb/indexes
+type queryIndex struct {
+	DesignDoc string
+	Name      string
+}
+
+// queryableFields lists fields allowed in filter and their index.
+// Keep in sync with META-INF/statedb/couchdb/indexes.
+var queryableFields = map[string]queryIndex{
+	"certificate_id": {DesignDoc: "indexCertificateIdDoc", Name: "indexCertificateId"},
+	"issuer":         {DesignDoc: "indexIssuerDoc", Name: "indexIssuer"},
+	"issuer_ref":     {DesignDoc: "indexIssuerRefDoc", Name: "indexIssuerRef"},
+	"owner":          {DesignDoc: "indexOwnerDoc", Name: "indexOwner"},
+}
+
+// emailFields lists queryable fields holding email. Clients other than platform service may only filter them
+// on their own email, so tokens of other holders cannot be listed.
+var emailFields = map[string]bool{
+	"issuer": true,
+	"owner":  true,
+}
+
+// queryOperators maps filter operators into CouchDB selector operators
+var queryOperators = map[string]string{
+	"eq":  "$eq",
+	"gt":  "$gt",
+	"gte": "$gte",
+	"lt":  "$lt",
+	"lte": "$lte",
+	"in":  "$in",
+}
+
+// QueryFilter describes structured query of tokens. All conditions must match.
+type QueryFilter struct {
+	Conditions []QueryCondition `json:"conditions"`
+	Sort       []QuerySort      `json:"sort,omitempty"`
+	Limit      int32            `json:"limit,omitempty"`
+	Bookmark   string           `json:"bookmark,omitempty"`
+}
+
+// QueryCondition describes condition on indexed field. Operator: eq, gt, gte, lt, lte or in (value is array).
+type QueryCondition struct {
+	Field    string      `json:"field"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+// QuerySort describes sort order on field used in conditions. Direction: asc (default) or desc.
+type QuerySort struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction,omitempty"`
+}
+
+// QueryRecordsByFilter perform a query for tokens with structured filter on indexed fields.
+// Clients other than platform service must filter owner or issuer equal to their own email.
+func (s *SmartContract) QueryRecordsByFilter(ctx contractapi.TransactionContextInterface, filter QueryFilter) (*PaginatedQueryResult, error) {
+	err := assertOwnEmailFilter(ctx, &filter)
+	if err != nil {
+		return nil, err
+	}
+
+	queryString, err := buildQuery(&filter)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = DefaultQueryLimit
+	}
+
+	if limit < 0 || limit > MaxPageSize {
+		return nil, fmt.Errorf("Limit must be between 1 and %d", MaxPageSize)
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, limit, filter.Bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	records, err := constructQueryResultsFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:      records,
+		FetchedCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:     responseMetadata.Bookmark,
+	}, nil
+}
+
+// buildQuery validates filter against queryable fields and converts it into CouchDB query
+func buildQuery(filter *QueryFilter) (string, error) {
+	if len(filter.Conditions) == 0 {
+		return "", fmt.Errorf("Filter must contain at least one condition")
+	}
+
+	selector := map[string]map[string]interface{}{}
+
+	for _, condition := range filter.Conditions {
+		if _, ok := queryableFields[condition.Field]; !ok {
+			return "", fmt.Errorf("Field %s is not queryable", condition.Field)
+		}
+
+		operator, ok := queryOperators[condition.Operator]
+		if !ok {
+			return "", fmt.Errorf("Invalid operator %s of field %s", condition.Operator, condition.Field)
+		}
+
+		if operator == "$in" {
+			if _, ok := condition.Value.([]interface{}); !ok {
+				return "", fmt.Errorf("Value of operator in must be an array")
+			}
+		} else {
+			switch condition.Value.(type) {
+			case string, float64, bool:
+			default:
+				return "", fmt.Errorf("Value of field %s must be string, number or boolean", condition.Field)
+			}
+		}
+
+		if selector[condition.Field] == nil {
+			selector[condition.Field] = map[string]interface{}{}
+		}
+		selector[condition.Field][operator] = condition.Value
+	}
+
+	// Indexes are single field, CouchDB sorts only with index on the sort field
+	if len(filter.Sort) > 1 {
+		return "", fmt.Errorf("Filter can sort on one field only")
+	}
+
+	sort := []map[string]string{}
+	for _, order := range filter.Sort {
+		if _, ok := selector[order.Field]; !ok {
+			return "", fmt.Errorf("Sort field %s must be used in conditions", order.Field)
+		}
+
+		direction := order.Direction
+		if direction == "" {
+			direction = "asc"
+		}
+
+		if direction != "asc" && direction != "desc" {
+			return "", fmt.Errorf("Invalid sort direction %s", order.Direction)
+		}
+
+		sort = append(sort, map[string]string{order.Field: direction})
+	}
+
+	index := queryableFields[filter.Conditions[0].Field]
+	if len(filter.Sort) > 0 {
+		index = queryableFields[filter.Sort[0].Field]
+	}
+	query := map[string]interface{}{
+		"selector":  selector,
+		"use_index": []string{"_design/" + index.DesignDoc, index.Name},
+	}
+
+	if len(sort) > 0 {
+		query["sort"] = sort
+	}
+
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+
+	return string(queryBytes), nil
+}
+
+// assertOwnEmailFilter verifies filter of client other than platform service is limited to tokens owned or issued
+// by client email: every owner and issuer condition equals client email, and at least one is present
+func assertOwnEmailFilter(ctx contractapi.TransactionContextInterface, filter *QueryFilter) error {
+	email, isService, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if isService {
+		return nil
+	}
+
+	scoped := false
+	for _, condition := range filter.Conditions {
+		if !emailFields[condition.Field] {
+			continue
+		}
+
+		value, ok := condition.Value.(string)
+		if email == "" || condition.Operator != "eq" || !ok || normalizeEmail(value) != email {
+			return fmt.Errorf("Field %s can only be filtered equal to client email", condition.Field)
+		}
+
+		scoped = true
+	}
+
+	if !scoped {
+		return fmt.Errorf("Filter must contain owner or issuer condition equal to client email")
+	}
+
+	return nil
+}
+
+// assertQueryAdmin verifies the submitting client is allowed to run raw state database queries
+func assertQueryAdmin(ctx contractapi.TransactionContextInterface) error {
+	return assertPlatformAttribute(ctx, QueryAdminAttribute, "query admin")
+}
+
+// assertPlatformAttribute verifies the submitting client is platform organization identity with attribute set to true
+func assertPlatformAttribute(ctx contractapi.TransactionContextInterface, attribute, role string) error {
+	platformMspId, err := queryPlatformMspId(ctx)
+	if err != nil {
+		return err
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	if mspId != platformMspId {
+		return fmt.Errorf("Client identity of %s is not %s", mspId, role)
+	}
+
+	err = ctx.GetClientIdentity().AssertAttributeValue(attribute, "true")
+	if err != nil {
+		return fmt.Errorf("Client identity is not %s. %s", role, err.Error())
+	}
+
+	return nil
+}
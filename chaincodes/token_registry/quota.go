@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	// Embedded time zone database, so quota periods do not depend on zoneinfo installed on peers
+	_ "time/tzdata"
+)
+
+// Quota periods of token quota replenishment. Period boundaries are computed in quota timezone of token.
+// - Daily: every day at midnight.
+// - Weekly: every Monday at midnight.
+// - Monthly: first day of every month at midnight.
+// - Yearly: first day of every year at midnight.
+// - Custom: every quota period days at midnight, counted from 1970-01-01.
+const (
+	QuotaPeriodDaily   = "DAILY"
+	QuotaPeriodWeekly  = "WEEKLY"
+	QuotaPeriodMonthly = "MONTHLY"
+	QuotaPeriodYearly  = "YEARLY"
+	QuotaPeriodCustom  = "CUSTOM"
+
+	DefaultQuotaTimezone = "UTC"
+
+	// MaxQuotaPeriodDays is maximum number of days of custom quota period
+	MaxQuotaPeriodDays = 3660
+)
+
+// validateQuotaPeriod returns error if quota period, its days or timezone is invalid
+func validateQuotaPeriod(quotaPeriod string, quotaPeriodDays int64, timezone string) error {
+	switch quotaPeriod {
+	case QuotaPeriodDaily, QuotaPeriodWeekly, QuotaPeriodMonthly, QuotaPeriodYearly:
+		if quotaPeriodDays != 0 {
+			return fmt.Errorf("Quota period days only allowed for %s quota period", QuotaPeriodCustom)
+		}
+
+	case QuotaPeriodCustom:
+		if quotaPeriodDays <= 0 || quotaPeriodDays > MaxQuotaPeriodDays {
+			return fmt.Errorf("Quota period days must be between 1 and %d", MaxQuotaPeriodDays)
+		}
+
+	default:
+		return fmt.Errorf("Invalid quota period: %s", quotaPeriod)
+	}
+
+	_, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("Invalid quota timezone %s. %s", timezone, err.Error())
+	}
+
+	return nil
+}
+
+// quotaLocation returns quota timezone of token. Tokens issued before quota timezone introduced use UTC.
+func quotaLocation(token *AccessTokenRegistry) *time.Location {
+	if token.QuotaTimezone == "" {
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(token.QuotaTimezone)
+	if err != nil {
+		// Timezone is validated at issuance
+		return time.UTC
+	}
+
+	return location
+}
+
+// quotaPeriodStart returns start of quota period of token containing t.
+// Tokens issued before quota period introduced are replenished monthly.
+func quotaPeriodStart(token *AccessTokenRegistry, t time.Time) time.Time {
+	location := quotaLocation(token)
+	year, month, day := t.In(location).Date()
+
+	switch token.QuotaPeriod {
+	case QuotaPeriodDaily:
+		return time.Date(year, month, day, 0, 0, 0, 0, location)
+
+	case QuotaPeriodWeekly:
+		date := time.Date(year, month, day, 0, 0, 0, 0, location)
+		daysSinceMonday := (int(date.Weekday()) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, location)
+
+	case QuotaPeriodYearly:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, location)
+
+	case QuotaPeriodCustom:
+		// Day number of local date since 1970-01-01
+		days := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400
+		days -= days % token.QuotaPeriodDays
+		return time.Date(1970, time.January, 1+int(days), 0, 0, 0, 0, location)
+
+	default:
+		return time.Date(year, month, 1, 0, 0, 0, 0, location)
+	}
+}
+
+// quotaPeriodElapsed returns true if quota period of token refilled last has ended
+func quotaPeriodElapsed(token *AccessTokenRegistry, clock Clock) bool {
+	if token.MonthlyTokenQuota == 0 {
+		return false
+	}
+
+	start := quotaPeriodStart(token, clock.Now()).Unix()
+	lastStart := token.QuotaPeriodStart
+
+	// Tokens issued before quota period start introduced only know when they were used last
+	if lastStart == 0 {
+		if token.LastUsedAt == 0 {
+			return false
+		}
+		lastStart = quotaPeriodStart(token, time.Unix(token.LastUsedAt, 0)).Unix()
+	}
+
+	return start > lastStart
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("Failed to load location %s. %s", name, err.Error())
+	}
+
+	return location
+}
+
+func TestQuotaPeriodStart(t *testing.T) {
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name            string
+		quotaPeriod     string
+		quotaPeriodDays int64
+		timezone        string
+		at              time.Time
+		expected        time.Time
+	}{
+		{
+			name:        "daily",
+			quotaPeriod: QuotaPeriodDaily,
+			at:          time.Date(2025, time.March, 10, 15, 30, 0, 0, time.UTC),
+			expected:    time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "weekly across new year",
+			quotaPeriod: QuotaPeriodWeekly,
+			at:          time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC),
+			expected:    time.Date(2025, time.December, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "weekly on monday",
+			quotaPeriod: QuotaPeriodWeekly,
+			at:          time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC),
+			expected:    time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "monthly",
+			quotaPeriod: QuotaPeriodMonthly,
+			at:          time.Date(2026, time.March, 15, 8, 0, 0, 0, time.UTC),
+			expected:    time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "yearly",
+			quotaPeriod: QuotaPeriodYearly,
+			at:          time.Date(2025, time.December, 31, 23, 59, 59, 0, time.UTC),
+			expected:    time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "daily in timezone ahead of UTC",
+			quotaPeriod: QuotaPeriodDaily,
+			timezone:    "Asia/Tokyo",
+			at:          time.Date(2025, time.March, 10, 16, 0, 0, 0, time.UTC),
+			expected:    time.Date(2025, time.March, 11, 0, 0, 0, 0, tokyo),
+		},
+		{
+			name:        "monthly in timezone behind UTC",
+			quotaPeriod: QuotaPeriodMonthly,
+			timezone:    "America/New_York",
+			at:          time.Date(2025, time.April, 1, 2, 0, 0, 0, time.UTC),
+			expected:    time.Date(2025, time.March, 1, 0, 0, 0, 0, newYork),
+		},
+		{
+			name:            "custom days",
+			quotaPeriod:     QuotaPeriodCustom,
+			quotaPeriodDays: 10,
+			at:              time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC),
+			expected:        time.Date(2024, time.December, 23, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:            "custom days on period boundary",
+			quotaPeriod:     QuotaPeriodCustom,
+			quotaPeriodDays: 10,
+			at:              time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC),
+			expected:        time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "legacy token without quota period",
+			at:       time.Date(2025, time.February, 20, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token := &AccessTokenRegistry{
+				QuotaPeriod:     test.quotaPeriod,
+				QuotaPeriodDays: test.quotaPeriodDays,
+				QuotaTimezone:   test.timezone,
+			}
+
+			start := quotaPeriodStart(token, test.at)
+			if !start.Equal(test.expected) {
+				t.Errorf("Expected quota period start %s, got %s", test.expected, start)
+			}
+		})
+	}
+}
+
+func TestReplenishAccessToken(t *testing.T) {
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+
+	tests := []struct {
+		name             string
+		quotaPeriod      string
+		quotaPeriodDays  int64
+		timezone         string
+		quotaPeriodStart time.Time
+		lastUsedAt       time.Time
+		now              time.Time
+		replenished      bool
+	}{
+		{
+			name:             "monthly same month next year",
+			quotaPeriod:      QuotaPeriodMonthly,
+			quotaPeriodStart: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			now:              time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC),
+			replenished:      true,
+		},
+		{
+			name:             "monthly same period",
+			quotaPeriod:      QuotaPeriodMonthly,
+			quotaPeriodStart: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			now:              time.Date(2025, time.March, 31, 23, 59, 59, 0, time.UTC),
+			replenished:      false,
+		},
+		{
+			name:             "weekly same week across new year",
+			quotaPeriod:      QuotaPeriodWeekly,
+			quotaPeriodStart: time.Date(2025, time.December, 29, 0, 0, 0, 0, time.UTC),
+			now:              time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+			replenished:      false,
+		},
+		{
+			name:             "weekly next week",
+			quotaPeriod:      QuotaPeriodWeekly,
+			quotaPeriodStart: time.Date(2025, time.December, 29, 0, 0, 0, 0, time.UTC),
+			now:              time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC),
+			replenished:      true,
+		},
+		{
+			name:             "daily next local day still same UTC day",
+			quotaPeriod:      QuotaPeriodDaily,
+			timezone:         "Asia/Tokyo",
+			quotaPeriodStart: time.Date(2025, time.March, 10, 0, 0, 0, 0, tokyo),
+			now:              time.Date(2025, time.March, 10, 16, 0, 0, 0, time.UTC),
+			replenished:      true,
+		},
+		{
+			name:             "daily same local day",
+			quotaPeriod:      QuotaPeriodDaily,
+			timezone:         "Asia/Tokyo",
+			quotaPeriodStart: time.Date(2025, time.March, 10, 0, 0, 0, 0, tokyo),
+			now:              time.Date(2025, time.March, 10, 14, 0, 0, 0, time.UTC),
+			replenished:      false,
+		},
+		{
+			name:             "custom days same period",
+			quotaPeriod:      QuotaPeriodCustom,
+			quotaPeriodDays:  10,
+			quotaPeriodStart: time.Date(2024, time.December, 23, 0, 0, 0, 0, time.UTC),
+			now:              time.Date(2025, time.January, 1, 23, 0, 0, 0, time.UTC),
+			replenished:      false,
+		},
+		{
+			name:             "custom days next period",
+			quotaPeriod:      QuotaPeriodCustom,
+			quotaPeriodDays:  10,
+			quotaPeriodStart: time.Date(2024, time.December, 23, 0, 0, 0, 0, time.UTC),
+			now:              time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC),
+			replenished:      true,
+		},
+		{
+			name:        "legacy token used in previous month",
+			lastUsedAt:  time.Date(2025, time.February, 28, 12, 0, 0, 0, time.UTC),
+			now:         time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			replenished: true,
+		},
+		{
+			name:        "legacy token used in same month",
+			lastUsedAt:  time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			now:         time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC),
+			replenished: false,
+		},
+		{
+			name:        "legacy token never used",
+			now:         time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC),
+			replenished: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token := &AccessTokenRegistry{
+				QuotaPeriod:       test.quotaPeriod,
+				QuotaPeriodDays:   test.quotaPeriodDays,
+				QuotaTimezone:     test.timezone,
+				MonthlyTokenQuota: 5,
+				Amount:            1,
+				AccessQuota:       2,
+				AvailableAccesses: 1,
+			}
+			if !test.quotaPeriodStart.IsZero() {
+				token.QuotaPeriodStart = test.quotaPeriodStart.Unix()
+			}
+			if !test.lastUsedAt.IsZero() {
+				token.LastUsedAt = test.lastUsedAt.Unix()
+			}
+
+			clock := fixedClock{now: test.now}
+			replenishAccessToken(token, clock)
+
+			if test.replenished {
+				if token.Amount != 5 || token.AvailableAccesses != 10 {
+					t.Errorf("Expected token replenished to 5 tokens and 10 accesses, got %d tokens and %d accesses",
+						token.Amount, token.AvailableAccesses)
+				}
+			} else if token.Amount != 1 || token.AvailableAccesses != 1 {
+				t.Errorf("Expected token not replenished, got %d tokens and %d accesses",
+					token.Amount, token.AvailableAccesses)
+			}
+
+			expectedStart := quotaPeriodStart(token, test.now).Unix()
+			if token.QuotaPeriodStart != expectedStart {
+				t.Errorf("Expected quota period start %d, got %d", expectedStart, token.QuotaPeriodStart)
+			}
+		})
+	}
+}
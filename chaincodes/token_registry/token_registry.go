@@ -20,7 +20,11 @@ type SmartContract struct {
 // Owner: owner email address
 // Transferable: boolean flag to identify transferable capability
 // Amount: amount of tokens hold in this address
-// MonthlyTokenQuota: monthly token quota allowed. If zero means, no refill given after quota spent out.
+// MonthlyTokenQuota: token quota refilled every quota period (name kept for existing records). If zero means, no refill given after quota spent out.
+// QuotaPeriod: quota period of token quota refill (DAILY, WEEKLY, MONTHLY, YEARLY or CUSTOM), empty means MONTHLY
+// QuotaPeriodDays: number of days of CUSTOM quota period
+// QuotaTimezone: IANA timezone of quota period boundaries, empty means UTC
+// QuotaPeriodStart: start of quota period token quota refilled last (unix seconds)
 // AccessQuota: access quota per one-token.
 // AvailableAccesses: total remaining access quota of all tokens hold
 // ExpiryDate: expiration date of tokens (if any specified) (nullable)
@@ -28,22 +32,28 @@ type SmartContract struct {
 // Issuer: issuer email address
 // IssuerRef: token_id references used to issued this tokens (nullable)
 // IsRevoked: boolean flag if token has been revoked
+// Operators: emails of operators delegated by owner to consume and change owner of tokens
 
 // AccessTokenRegistry describes access tokens usage within platform
 type AccessTokenRegistry struct {
-	TokenId           string `json:"token_id"`
-	CertificateId     string `json:"certificate_id"`
-	Owner             string `json:"owner"`
-	Transferable      bool   `json:"transferable"`
-	Amount            int64  `json:"amount"`
-	MonthlyTokenQuota int64  `json:"monthly_token_quota"`
-	AccessQuota       int64  `json:"access_quota"`
-	AvailableAccesses int64  `json:"available_accesses"`
-	ExpiryDate        int64  `json:"expiry_date"`
-	LastUsedAt        int64  `json:"last_used_at"`
-	Issuer            string `json:"issuer"`
-	IssuerRef         string `json:"issuer_ref"`
-	IsRevoked         bool   `json:"is_revoked"`
+	TokenId           string   `json:"token_id"`
+	CertificateId     string   `json:"certificate_id"`
+	Owner             string   `json:"owner"`
+	Transferable      bool     `json:"transferable"`
+	Amount            int64    `json:"amount"`
+	MonthlyTokenQuota int64    `json:"monthly_token_quota"`
+	QuotaPeriod       string   `json:"quota_period,omitempty"`
+	QuotaPeriodDays   int64    `json:"quota_period_days,omitempty"`
+	QuotaTimezone     string   `json:"quota_timezone,omitempty"`
+	QuotaPeriodStart  int64    `json:"quota_period_start,omitempty"`
+	AccessQuota       int64    `json:"access_quota"`
+	AvailableAccesses int64    `json:"available_accesses"`
+	ExpiryDate        int64    `json:"expiry_date"`
+	LastUsedAt        int64    `json:"last_used_at"`
+	Issuer            string   `json:"issuer"`
+	IssuerRef         string   `json:"issuer_ref"`
+	IsRevoked         bool     `json:"is_revoked"`
+	Operators         []string `json:"operators,omitempty"`
 }
 
 // QueryResult structure used for handling result of query
@@ -52,6 +62,13 @@ type QueryResult struct {
 	Record *AccessTokenRegistry `json:"record"`
 }
 
+// PaginatedQueryResult structure used for handling result of paginated query
+type PaginatedQueryResult struct {
+	Records      []*QueryResult `json:"records"`
+	FetchedCount int32          `json:"fetchedCount"`
+	Bookmark     string         `json:"bookmark"`
+}
+
 // HistoryQueryResult used for handling result modification history of certificate
 type HistoryQueryResult struct {
 	Value     *AccessTokenRegistry `json:"value"`
@@ -62,15 +79,46 @@ type HistoryQueryResult struct {
 
 const (
 	IssuerRoot = "ROOT"
+
+	// MaxPageSize is maximum number of records per page of paginated query
+	MaxPageSize = 1000
+
+	// IssuerRegistryChaincode is the chaincode name of issuer registry on the same channel, holding platform MSP ID
+	IssuerRegistryChaincode = "issuer_registry"
 )
 
-// IssueRootToken grant root access token to Academic and Certificate Holder
+// IssueRootToken grant root access token to Academic and Certificate Holder.
+// Only platform service or issuer of the certificate allowed, the certificate must exist in certificate info
+// and must not be revoked or suspended.
 func (s *SmartContract) IssueRootToken(ctx contractapi.TransactionContextInterface, tokenId, certificateId, owner string) error {
 	_, err := s.QueryToken(ctx, tokenId)
 	if err == nil {
 		return fmt.Errorf("TokenId %s already exists", tokenId)
 	}
 
+	if normalizeEmail(owner) == "" {
+		return fmt.Errorf("Owner must not be empty")
+	}
+
+	certificate, err := queryCertificate(ctx, certificateId)
+	if err != nil {
+		return err
+	}
+
+	if certificate == nil {
+		return fmt.Errorf("Certificate %s does not exist in certificate info", certificateId)
+	}
+
+	err = assertCertificateIssuer(ctx, certificateId, certificate)
+	if err != nil {
+		return err
+	}
+
+	err = assertCertificateNotRevoked(ctx, certificateId)
+	if err != nil {
+		return err
+	}
+
 	token := AccessTokenRegistry{
 		TokenId:           tokenId,
 		CertificateId:     certificateId,
@@ -92,22 +140,48 @@ func (s *SmartContract) IssueRootToken(ctx contractapi.TransactionContextInterfa
 		return err
 	}
 
-	return ctx.GetStub().PutState(tokenId, tokenBytes)
+	err = ctx.GetStub().PutState(tokenId, tokenBytes)
+	if err != nil {
+		return err
+	}
+
+	return putTokenIndexes(ctx, tokenId, &token)
 }
 
-// IssueTransferableToken grant transferable access token. Require root token (issuer) reference.
+// IssueTransferableToken grant transferable access token with monthly token quota in UTC. Require root token (issuer) reference.
 func (s *SmartContract) IssueTransferableToken(ctx contractapi.TransactionContextInterface, tokenId, issuerTokenId, recipient string,
 	amount, monthlyTokenQuota, expiryDate int64) error {
 
-	if amount <= 0 || monthlyTokenQuota < 0 {
+	return s.IssueTransferableTokenWithQuotaPeriod(ctx, tokenId, issuerTokenId, recipient, amount, monthlyTokenQuota, expiryDate,
+		QuotaPeriodMonthly, 0, DefaultQuotaTimezone)
+}
+
+// IssueTransferableTokenWithQuotaPeriod grant transferable access token refilled with tokenQuota every quota period.
+// Period boundaries are computed in timezone (IANA name, example: Asia/Kuala_Lumpur). Require root token (issuer) reference,
+// only its owner, issuer or delegated operator allowed, the certificate must not be revoked or suspended.
+func (s *SmartContract) IssueTransferableTokenWithQuotaPeriod(ctx contractapi.TransactionContextInterface,
+	tokenId, issuerTokenId, recipient string, amount, tokenQuota, expiryDate int64,
+	quotaPeriod string, quotaPeriodDays int64, timezone string) error {
+
+	if amount <= 0 || tokenQuota < 0 {
 		return fmt.Errorf("Amount and Monthly Token Quota must be positive integer")
 	}
 
-	if expiryDate > 0 && expiryDate < time.Now().Unix() {
+	err := validateQuotaPeriod(quotaPeriod, quotaPeriodDays, timezone)
+	if err != nil {
+		return err
+	}
+
+	clock, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+
+	if expiryDate > 0 && expiryDate < clock.Now().Unix() {
 		return fmt.Errorf("Expiry date must be greater than current time")
 	}
 
-	_, err := s.QueryToken(ctx, tokenId)
+	_, err = s.QueryToken(ctx, tokenId)
 	if err == nil {
 		return fmt.Errorf("TokenId %s already exists", tokenId)
 	}
@@ -117,6 +191,11 @@ func (s *SmartContract) IssueTransferableToken(ctx contractapi.TransactionContex
 		return fmt.Errorf("Error query issuer token: %s", err.Error())
 	}
 
+	err = assertTokenController(ctx, issuerToken)
+	if err != nil {
+		return err
+	}
+
 	// Issuer must be root to grant transferable access token
 	if !isRootToken(issuerToken) {
 		return fmt.Errorf("Issuer does not have permission to grant transferable tokens")
@@ -126,13 +205,21 @@ func (s *SmartContract) IssueTransferableToken(ctx contractapi.TransactionContex
 		return fmt.Errorf("Issuer token has been revoked")
 	}
 
+	err = assertCertificateNotRevoked(ctx, issuerToken.CertificateId)
+	if err != nil {
+		return err
+	}
+
 	token := AccessTokenRegistry{
 		TokenId:           tokenId,
 		CertificateId:     issuerToken.CertificateId,
 		Owner:             recipient,
 		Transferable:      true,
 		Amount:            amount,
-		MonthlyTokenQuota: monthlyTokenQuota,
+		MonthlyTokenQuota: tokenQuota,
+		QuotaPeriod:       quotaPeriod,
+		QuotaPeriodDays:   quotaPeriodDays,
+		QuotaTimezone:     timezone,
 		AccessQuota:       1,
 		AvailableAccesses: amount,
 		ExpiryDate:        expiryDate,
@@ -141,16 +228,23 @@ func (s *SmartContract) IssueTransferableToken(ctx contractapi.TransactionContex
 		IssuerRef:         issuerTokenId,
 		IsRevoked:         false,
 	}
+	token.QuotaPeriodStart = quotaPeriodStart(&token, clock.Now()).Unix()
 
 	tokenBytes, err := json.Marshal(token)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(tokenId, tokenBytes)
+	err = ctx.GetStub().PutState(tokenId, tokenBytes)
+	if err != nil {
+		return err
+	}
+
+	return putTokenIndexes(ctx, tokenId, &token)
 }
 
-// IssueStandardToken transfer access token to the external users such employer and non-registered user in platform
+// IssueStandardToken transfer access token to the external users such employer and non-registered user in platform.
+// Only the issuer token owner, issuer or delegated operator allowed, the certificate must not be revoked or suspended.
 func (s *SmartContract) IssueStandardToken(ctx contractapi.TransactionContextInterface, tokenId, issuerTokenId, recipient string,
 	amount, accessQuota, expiryDate int64) error {
 
@@ -158,11 +252,20 @@ func (s *SmartContract) IssueStandardToken(ctx contractapi.TransactionContextInt
 		return fmt.Errorf("Amount and Access Quota must be greater than zero")
 	}
 
-	if expiryDate > 0 && expiryDate < time.Now().Unix() {
+	if amount > math.MaxInt64/accessQuota {
+		return fmt.Errorf("Amount multiplied by Access Quota exceeds maximum accesses")
+	}
+
+	clock, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+
+	if expiryDate > 0 && expiryDate < clock.Now().Unix() {
 		return fmt.Errorf("Expiry date must be greater than current time")
 	}
 
-	_, err := s.QueryToken(ctx, tokenId)
+	_, err = s.QueryToken(ctx, tokenId)
 	if err == nil {
 		return fmt.Errorf("TokenId %s already exists", tokenId)
 	}
@@ -172,12 +275,22 @@ func (s *SmartContract) IssueStandardToken(ctx contractapi.TransactionContextInt
 		return err
 	}
 
+	err = assertTokenController(ctx, issuerToken)
+	if err != nil {
+		return err
+	}
+
 	// Assert issuer token valid
-	tokenStatus := checkTokenStatus(issuerToken)
+	tokenStatus := checkTokenStatus(issuerToken, clock)
 	if tokenStatus != "VALID" {
 		return fmt.Errorf("Issuer token is not valid. Status: %s", tokenStatus)
 	}
 
+	err = assertCertificateNotRevoked(ctx, issuerToken.CertificateId)
+	if err != nil {
+		return err
+	}
+
 	isIssuerRoot := isRootToken(issuerToken)
 	issuerAmountBefore := issuerToken.Amount
 	issuerAccessesBefore := issuerToken.AvailableAccesses
@@ -191,20 +304,20 @@ func (s *SmartContract) IssueStandardToken(ctx contractapi.TransactionContextInt
 			return fmt.Errorf("Issuer does not have permission to issuing transferable tokens")
 		}
 
+		// Handle token quota refill
+		replenishAccessToken(issuerToken, clock)
+		issuerAmountBefore = issuerToken.Amount
+		issuerAccessesBefore = issuerToken.AvailableAccesses
+
 		// Assert issuer have enough balance
 		if issuerToken.AvailableAccesses < (amount * accessQuota) {
 			return fmt.Errorf("Issuer does not have enough amount to transfer")
 		}
 
-		// Handle monthly token quota
-		replenishAccessToken(issuerToken)
-		issuerAmountBefore = issuerToken.Amount
-		issuerAccessesBefore = issuerToken.AvailableAccesses
-
 		// Deduct issuer token amount
 		issuerToken.AvailableAccesses -= (amount * accessQuota)
 		issuerToken.Amount = int64(math.Ceil(float64(issuerToken.AvailableAccesses) / float64(issuerToken.AccessQuota)))
-		issuerToken.LastUsedAt = time.Now().Unix()
+		issuerToken.LastUsedAt = clock.Now().Unix()
 
 		issuerTokenBytes, err := json.Marshal(issuerToken)
 		if err != nil {
@@ -240,6 +353,10 @@ func (s *SmartContract) IssueStandardToken(ctx contractapi.TransactionContextInt
 	}
 
 	err = ctx.GetStub().PutState(tokenId, tokenBytes)
+	if err == nil {
+		err = putTokenIndexes(ctx, tokenId, &token)
+	}
+
 	if err != nil {
 		// If issuer is root, then return error and nothing to rollback
 		if isIssuerRoot {
@@ -249,7 +366,7 @@ func (s *SmartContract) IssueStandardToken(ctx contractapi.TransactionContextInt
 		// Rollback (refund) issuer token amount
 		issuerToken.Amount = issuerAmountBefore
 		issuerToken.AvailableAccesses = issuerAccessesBefore
-		issuerToken.LastUsedAt = time.Now().Unix()
+		issuerToken.LastUsedAt = clock.Now().Unix()
 
 		issuerTokenBytes, err1 := json.Marshal(issuerToken)
 		if err1 != nil {
@@ -267,19 +384,36 @@ func (s *SmartContract) IssueStandardToken(ctx contractapi.TransactionContextInt
 	return nil
 }
 
-// ChangeTokenOwner change token owner (recipient) for reset or resend email notification
+// ChangeTokenOwner change token owner (recipient) for reset or resend email notification.
+// Only the token owner, issuer or delegated operator allowed, the certificate must not be revoked or suspended.
+// Operators of previous owner are removed.
 func (s *SmartContract) ChangeTokenOwner(ctx contractapi.TransactionContextInterface, tokenId, owner string) error {
 	token, err := s.QueryToken(ctx, tokenId)
 	if err != nil {
 		return err
 	}
 
+	err = assertTokenController(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	clock, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Assert token status valid
-	tokenStatus := checkTokenStatus(token)
+	tokenStatus := checkTokenStatus(token, clock)
 	if tokenStatus != "VALID" {
 		return fmt.Errorf("Error in change token owner. TokenId: %s, Status: %s", tokenId, tokenStatus)
 	}
 
+	err = assertCertificateNotRevoked(ctx, token.CertificateId)
+	if err != nil {
+		return err
+	}
+
 	// Assert not root token
 	if isRootToken(token) {
 		return fmt.Errorf("Error in change token owner. TokenId: %s is root token", tokenId)
@@ -292,6 +426,7 @@ func (s *SmartContract) ChangeTokenOwner(ctx contractapi.TransactionContextInter
 
 	// Change token owner
 	token.Owner = owner
+	token.Operators = nil
 
 	// Write token changes
 	tokenBytes, err := json.Marshal(token)
@@ -302,23 +437,44 @@ func (s *SmartContract) ChangeTokenOwner(ctx contractapi.TransactionContextInter
 	return ctx.GetStub().PutState(tokenId, tokenBytes)
 }
 
-// ConsumeToken deduct available access by 1 from tokenId
+// ConsumeToken deduct available access by 1 from tokenId.
+// Only the token owner, issuer or delegated operator allowed, the certificate must not be revoked or suspended in certificate info.
 func (s *SmartContract) ConsumeToken(ctx contractapi.TransactionContextInterface, tokenId string) error {
 	token, err := s.QueryToken(ctx, tokenId)
 	if err != nil {
 		return err
 	}
 
+	err = assertTokenController(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	clock, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Assert token status valid
-	tokenStatus := checkTokenStatus(token)
+	tokenStatus := checkTokenStatus(token, clock)
 	if tokenStatus != "VALID" {
 		return fmt.Errorf("Error in consuming token. TokenId: %s, Status: %s", tokenId, tokenStatus)
 	}
 
+	// Tokens of revoked or suspended certificate stop working before they are revoked by RevokeTokensForCertificate
+	err = assertCertificateNotRevoked(ctx, token.CertificateId)
+	if err != nil {
+		return err
+	}
+
 	// If not root token, consume token
 	if !isRootToken(token) {
-		// Handle monthly token quota
-		replenishAccessToken(token)
+		// Handle token quota refill
+		replenishAccessToken(token, clock)
+
+		if token.AvailableAccesses <= 0 {
+			return fmt.Errorf("Error in consuming token. TokenId: %s, Status: SPENT", tokenId)
+		}
 
 		// Consume Available Accesses
 		token.AvailableAccesses -= 1
@@ -330,7 +486,7 @@ func (s *SmartContract) ConsumeToken(ctx contractapi.TransactionContextInterface
 	}
 
 	// Update last used timestamp
-	token.LastUsedAt = time.Now().Unix()
+	token.LastUsedAt = clock.Now().Unix()
 
 	// Write token changes
 	tokenBytes, err := json.Marshal(token)
@@ -341,14 +497,24 @@ func (s *SmartContract) ConsumeToken(ctx contractapi.TransactionContextInterface
 	return ctx.GetStub().PutState(tokenId, tokenBytes)
 }
 
-// RevokeToken revoke all tokens hold in tokenId
+// RevokeToken revoke all tokens hold in tokenId. Only the token owner, issuer or platform service allowed.
 func (s *SmartContract) RevokeToken(ctx contractapi.TransactionContextInterface, tokenId string) error {
 	token, err := s.QueryToken(ctx, tokenId)
 	if err != nil {
 		return err
 	}
 
-	tokenStatus := checkTokenStatus(token)
+	err = assertTokenOwnerOrIssuer(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	clock, err := newTxClock(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokenStatus := checkTokenStatus(token, clock)
 	if tokenStatus != "VALID" {
 		return fmt.Errorf("Token Id %s cannot be revoke, Current status: %s", tokenId, tokenStatus)
 	}
@@ -386,18 +552,35 @@ func (s *SmartContract) QueryToken(ctx contractapi.TransactionContextInterface,
 	return token, nil
 }
 
-// QueryTokenStatus get token status of tokenId
+// QueryTokenStatus get token status of tokenId. Valid token of revoked or suspended certificate
+// reports CERTIFICATE_REVOKED or CERTIFICATE_SUSPENDED.
 func (s *SmartContract) QueryTokenStatus(ctx contractapi.TransactionContextInterface, tokenId string) (string, error) {
 	token, err := s.QueryToken(ctx, tokenId)
 	if err != nil {
 		return "", err
 	}
-	return checkTokenStatus(token), nil
+
+	clock, err := newTxClock(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tokenStatus := checkTokenStatus(token, clock)
+	if tokenStatus != "VALID" {
+		return tokenStatus, nil
+	}
+
+	return checkCertificateStatus(ctx, token.CertificateId)
 }
 
 // QueryRecords uses a query string to perform a query for certificates.
-// Query string matching state database syntax is passed in and executed as is.
+// Query string matching state database syntax is passed in and executed as is. Only query admin allowed,
+// use QueryRecordsByFilter otherwise.
 func (s *SmartContract) QueryRecords(ctx contractapi.TransactionContextInterface, queryString string) ([]*AccessTokenRegistry, error) {
+	if err := assertQueryAdmin(ctx); err != nil {
+		return nil, err
+	}
+
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, err
@@ -407,23 +590,73 @@ func (s *SmartContract) QueryRecords(ctx contractapi.TransactionContextInterface
 	return constructQueryResponseFromIterator(resultsIterator)
 }
 
+// QueryRecordsWithPagination uses a query string to perform a query for tokens page by page.
+// Pass bookmark returned by previous page to fetch the next page, empty bookmark fetch the first page.
+// Only query admin allowed, use QueryRecordsByFilter otherwise.
+func (s *SmartContract) QueryRecordsWithPagination(ctx contractapi.TransactionContextInterface,
+	queryString string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+
+	if err := assertQueryAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 || pageSize > MaxPageSize {
+		return nil, fmt.Errorf("Page size must be between 1 and %d", MaxPageSize)
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	records, err := constructQueryResultsFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:      records,
+		FetchedCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:     responseMetadata.Bookmark,
+	}, nil
+}
+
 func constructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorInterface) ([]*AccessTokenRegistry, error) {
+	results, err := constructQueryResultsFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
 	records := []*AccessTokenRegistry{}
+	for _, result := range results {
+		records = append(records, result.Record)
+	}
+
+	return records, nil
+}
+
+func constructQueryResultsFromIterator(resultsIterator shim.StateQueryIteratorInterface) ([]*QueryResult, error) {
+	results := []*QueryResult{}
 
 	for resultsIterator.HasNext() {
 		queryResult, err := resultsIterator.Next()
 		if err != nil {
 			return nil, err
 		}
+		// Skip transfer index entries stored under composite keys
+		if strings.HasPrefix(queryResult.Key, compositeKeyNamespace) {
+			continue
+		}
 		record := AccessTokenRegistry{}
 		err = json.Unmarshal(queryResult.Value, &record)
 		if err != nil {
 			return nil, err
 		}
-		records = append(records, &record)
+		results = append(results, &QueryResult{Key: queryResult.Key, Record: &record})
 	}
 
-	return records, nil
+	return results, nil
 }
 
 // GetHistoryForKey get modification history of access token
@@ -470,10 +703,10 @@ func isRootToken(token *AccessTokenRegistry) bool {
 
 // checkTokenStatus returns status of token.
 // - Revoked: token already revoked and cannot be used for further operation.
-// - Spent: token already spent out. No further quota available to consume. Token with Monthly Token Quota will replenish and status can be valid in the next month.
+// - Spent: token already spent out. No further quota available to consume. Token with token quota will replenish and status can be valid in the next quota period.
 // - Expired: token has been expired. There may be some remaining accesses hold.
 // - Valid: token still valid and can be consume.
-func checkTokenStatus(token *AccessTokenRegistry) string {
+func checkTokenStatus(token *AccessTokenRegistry, clock Clock) string {
 	if token == nil {
 		return "INVALID"
 	}
@@ -484,12 +717,12 @@ func checkTokenStatus(token *AccessTokenRegistry) string {
 
 	// For non-root token check requirements
 	if !isRootToken(token) {
-		if token.AvailableAccesses == 0 && token.MonthlyTokenQuota == 0 {
+		if token.AvailableAccesses <= 0 && !quotaPeriodElapsed(token, clock) {
 			return "SPENT"
 		}
 		if token.ExpiryDate != 0 {
 			tExpiryDate := time.Unix(token.ExpiryDate, 0)
-			if tExpiryDate.Before(time.Now()) {
+			if tExpiryDate.Before(clock.Now()) {
 				return "EXPIRED"
 			}
 		}
@@ -498,20 +731,18 @@ func checkTokenStatus(token *AccessTokenRegistry) string {
 	return "VALID"
 }
 
-// replenishAccessToken refill access token if issuer had monthly quota
-func replenishAccessToken(t *AccessTokenRegistry) {
+// replenishAccessToken refill access token with token quota once its quota period elapsed
+func replenishAccessToken(t *AccessTokenRegistry, clock Clock) {
 	if t == nil || t.MonthlyTokenQuota == 0 {
 		return
 	}
 
-	if t.LastUsedAt != 0 {
-		tlastUsedAt := time.Unix(t.LastUsedAt, 0)
-		if tlastUsedAt.Month() != time.Now().Month() {
-			t.Amount = t.MonthlyTokenQuota
-			t.AvailableAccesses = t.Amount * t.AccessQuota
-			t.LastUsedAt = time.Now().Unix()
-		}
+	if quotaPeriodElapsed(t, clock) {
+		t.Amount = t.MonthlyTokenQuota
+		t.AvailableAccesses = t.Amount * t.AccessQuota
 	}
+
+	t.QuotaPeriodStart = quotaPeriodStart(t, clock.Now()).Unix()
 }
 
 func main() {
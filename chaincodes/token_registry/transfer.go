@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// compositeKeyNamespace is the prefix of keys created by CreateCompositeKey
+const compositeKeyNamespace = "\x00"
+
+// transferTokenIndex maps root token, recipient and expiry date to token receiving transfers,
+// so repeated transfers to the same recipient accumulate in one token
+const transferTokenIndex = "transfer~root~owner~expiry"
+
+// transferRecipientIndex links token to recipient tokens of its transfers, so cascading revocation reaches them
+const transferRecipientIndex = "transfer~from~to"
+
+// TransferToken transfer amount of transferable tokens hold in fromTokenId to toOwner and returns recipient token id.
+// Recipient token keeps certificate and root token (issuer reference) of fromTokenId and its expiry date, transfers with the same
+// root token and expiry date accumulate in one token. Token quota is not transferred, recipient token is not refilled.
+// Only the token owner, issuer or delegated operator allowed, the certificate must not be revoked or suspended.
+func (s *SmartContract) TransferToken(ctx contractapi.TransactionContextInterface, fromTokenId, toOwner string, amount int64) (string, error) {
+	if amount <= 0 {
+		return "", fmt.Errorf("Amount must be greater than zero")
+	}
+
+	if normalizeEmail(toOwner) == "" {
+		return "", fmt.Errorf("Recipient must not be empty")
+	}
+
+	fromToken, err := s.QueryToken(ctx, fromTokenId)
+	if err != nil {
+		return "", err
+	}
+
+	err = assertTokenController(ctx, fromToken)
+	if err != nil {
+		return "", err
+	}
+
+	clock, err := newTxClock(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tokenStatus := checkTokenStatus(fromToken, clock)
+	if tokenStatus != "VALID" {
+		return "", fmt.Errorf("Error in transfer token. TokenId: %s, Status: %s", fromTokenId, tokenStatus)
+	}
+
+	err = assertCertificateNotRevoked(ctx, fromToken.CertificateId)
+	if err != nil {
+		return "", err
+	}
+
+	if isRootToken(fromToken) || !fromToken.Transferable {
+		return "", fmt.Errorf("TokenId %s is not transferable", fromTokenId)
+	}
+
+	if normalizeEmail(fromToken.Owner) == normalizeEmail(toOwner) {
+		return "", fmt.Errorf("Recipient already owns TokenId %s", fromTokenId)
+	}
+
+	// Handle token quota refill
+	replenishAccessToken(fromToken, clock)
+
+	accesses := amount * fromToken.AccessQuota
+	if fromToken.AvailableAccesses < accesses {
+		return "", fmt.Errorf("TokenId %s does not have enough amount to transfer", fromTokenId)
+	}
+
+	fromToken.AvailableAccesses -= accesses
+	fromToken.Amount = int64(math.Ceil(float64(fromToken.AvailableAccesses) / float64(fromToken.AccessQuota)))
+	fromToken.LastUsedAt = clock.Now().Unix()
+
+	fromTokenBytes, err := json.Marshal(fromToken)
+	if err != nil {
+		return "", err
+	}
+
+	err = ctx.GetStub().PutState(fromTokenId, fromTokenBytes)
+	if err != nil {
+		return "", err
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(transferTokenIndex,
+		[]string{fromToken.IssuerRef, normalizeEmail(toOwner), strconv.FormatInt(fromToken.ExpiryDate, 10)})
+	if err != nil {
+		return "", err
+	}
+
+	toTokenIdBytes, err := ctx.GetStub().GetState(indexKey)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	var toToken *AccessTokenRegistry
+	if toTokenIdBytes != nil {
+		toToken, err = s.QueryToken(ctx, string(toTokenIdBytes))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Previous recipient token no longer usable or changed owner through ChangeTokenOwner, start a new one
+	if toToken != nil && (toToken.IsRevoked || toToken.AccessQuota != fromToken.AccessQuota ||
+		normalizeEmail(toToken.Owner) != normalizeEmail(toOwner)) {
+		toToken = nil
+	}
+
+	isNewToken := toToken == nil
+	if isNewToken {
+		toToken = &AccessTokenRegistry{
+			TokenId:           transferTokenId(ctx.GetStub().GetTxID(), fromTokenId),
+			CertificateId:     fromToken.CertificateId,
+			Owner:             toOwner,
+			Transferable:      true,
+			Amount:            0,
+			MonthlyTokenQuota: 0,
+			AccessQuota:       fromToken.AccessQuota,
+			AvailableAccesses: 0,
+			ExpiryDate:        fromToken.ExpiryDate,
+			LastUsedAt:        0,
+			Issuer:            fromToken.Issuer,
+			IssuerRef:         fromToken.IssuerRef,
+			IsRevoked:         false,
+		}
+
+		_, err = s.QueryToken(ctx, toToken.TokenId)
+		if err == nil {
+			return "", fmt.Errorf("TokenId %s already exists", toToken.TokenId)
+		}
+
+		err = ctx.GetStub().PutState(indexKey, []byte(toToken.TokenId))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	toToken.AvailableAccesses += accesses
+	toToken.Amount = int64(math.Ceil(float64(toToken.AvailableAccesses) / float64(toToken.AccessQuota)))
+
+	toTokenBytes, err := json.Marshal(toToken)
+	if err != nil {
+		return "", err
+	}
+
+	err = ctx.GetStub().PutState(toToken.TokenId, toTokenBytes)
+	if err != nil {
+		return "", err
+	}
+
+	if isNewToken {
+		err = putTokenIndexes(ctx, toToken.TokenId, toToken)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = putIndexEntry(ctx, transferRecipientIndex, fromTokenId, toToken.TokenId)
+	if err != nil {
+		return "", err
+	}
+
+	return toToken.TokenId, nil
+}
+
+// transferTokenId derives token id (uuid format) of recipient token from transaction id, identical on every endorsing peer
+func transferTokenId(txId, fromTokenId string) string {
+	hash := sha256.Sum256([]byte(txId + ":" + fromTokenId))
+
+	// Version 5 (name based, SHA) and RFC 4122 variant bits
+	hash[6] = (hash[6] & 0x0f) | 0x50
+	hash[8] = (hash[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", hash[0:4], hash[4:6], hash[6:8], hash[8:10], hash[10:16])
+}
@@ -281,12 +281,28 @@ deploy_chaincode() {
 
 export CC_SEQUENCE=1
 export CC_VERSION=1.0
+export CC_NAME=issuer_registry
+deploy_chaincode
+
+sleep 10
+
+# Platform MSP ID is set once, admin attributes of all chaincodes are only trusted on identities of this MSP
+echo "=== Init Chaincode ${CC_NAME} ==="
+kubectl hlf chaincode invoke \
+    --config "${ORG1_NAME}.yaml" \
+    --user "${ORG1_ADMIN_USER}" \
+    --peer "${ORG1_PEER0}" \
+    --chaincode "${CC_NAME}" \
+    --channel "${CHANNEL_ID}" \
+    --fcn InitRegistry \
+    -a "${ORG1_MSP}"
+
 export CC_NAME=certificate_info
 deploy_chaincode
 
 export CC_NAME=certificate_template
 deploy_chaincode
 
-# Access token chaincode is not required for P2P organization setup
-# export CC_NAME=token_registry
-# deploy_chaincode
+# RevokeCertificate of certificate info revokes tokens in token registry
+export CC_NAME=token_registry
+deploy_chaincode